@@ -0,0 +1,130 @@
+package apiserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type principalKey struct{}
+
+// SetPrincipal records the authenticated caller of the request in its access
+// log line. Authentication middlewares call it once they identify the caller.
+func SetPrincipal(ctx context.Context, principal string) {
+	if slot, ok := ctx.Value(principalKey{}).(*string); ok {
+		*slot = principal
+	}
+}
+
+// AccessLogOptions tunes the access log.
+type AccessLogOptions struct {
+	// HealthSampleEvery logs one in N successful probe requests, none when 0.
+	HealthSampleEvery uint32
+	// SlowRequest logs the requests slower than it as warnings, disabled when 0.
+	SlowRequest time.Duration
+}
+
+type accessLogConfig struct {
+	AccessLogOptions
+	healthSampler zerolog.Sampler
+}
+
+// accessLog writes a zerolog line per request. Its options can be replaced
+// while the server runs.
+type accessLog struct {
+	config atomic.Pointer[accessLogConfig]
+	// probes holds the route patterns of the probes, set while the routes are mounted.
+	probes map[string]bool
+}
+
+func newAccessLog() *accessLog {
+	l := &accessLog{probes: make(map[string]bool)}
+	l.setOptions(AccessLogOptions{HealthSampleEvery: 1})
+	return l
+}
+
+func (l *accessLog) setOptions(options AccessLogOptions) {
+	config := &accessLogConfig{AccessLogOptions: options}
+	if options.HealthSampleEvery > 0 {
+		config.healthSampler = &zerolog.BasicSampler{N: options.HealthSampleEvery}
+	}
+	l.config.Store(config)
+}
+
+// SetAccessLogOptions replaces the options of the access log.
+func (a *ApiServer) SetAccessLogOptions(options AccessLogOptions) {
+	a.accessLog.setOptions(options)
+}
+
+// middleware logs the request once it is served: server errors as errors, slow
+// requests as warnings and the others as info. Successful probe requests are
+// sampled so that the orchestrator polling them doesn't flood the logs.
+func (l *accessLog) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started := time.Now()
+		principal := new(string)
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		latency := time.Since(started)
+
+		config := l.config.Load()
+		route := chi.RouteContext(r.Context()).RoutePattern()
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		slow := config.SlowRequest > 0 && latency >= config.SlowRequest
+
+		logger := log.Logger
+		if l.probes[route] && status < http.StatusBadRequest && !slow {
+			if config.healthSampler == nil {
+				return
+			}
+			logger = logger.Sample(config.healthSampler)
+		}
+
+		var event *zerolog.Event
+		switch {
+		case status >= http.StatusInternalServerError:
+			event = logger.Error()
+		case slow:
+			event = logger.Warn().Bool("slow", true)
+		default:
+			event = logger.Info()
+		}
+
+		event.
+			Str("request_id", middleware.GetReqID(r.Context())).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Str("route", route).
+			Int("status", status).
+			Int("bytes", ww.BytesWritten()).
+			Dur("latency_ms", latency).
+			Str("client_ip", clientIP(r)).
+			Str("user_agent", r.UserAgent())
+		if *principal != "" {
+			event.Str("principal", *principal)
+		}
+		if span := trace.SpanContextFromContext(r.Context()); span.HasTraceID() {
+			event.Str("trace_id", span.TraceID().String())
+		}
+		event.Msg("request")
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
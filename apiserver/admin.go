@@ -0,0 +1,169 @@
+package apiserver
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"template/apiserver/handlers"
+	"template/config"
+	"template/datastore/db/mysql"
+	"template/health"
+	"template/metrics"
+)
+
+// AdminServer exposes debugging endpoints on a listener of its own, never on
+// the public router.
+type AdminServer struct {
+	database  *mysql.DB
+	settings  func() *config.Settings
+	checks    *health.Registry
+	metrics   *metrics.Registry
+	startedAt time.Time
+}
+
+// NewAdminServer creates the admin server. A nil database reports the pools as
+// disabled, settings returns the current settings as they get reloaded.
+func NewAdminServer(database *mysql.DB, settings func() *config.Settings, checks *health.Registry, metrics *metrics.Registry) *AdminServer {
+	return &AdminServer{
+		database:  database,
+		settings:  settings,
+		checks:    checks,
+		metrics:   metrics,
+		startedAt: time.Now(),
+	}
+}
+
+func (a *AdminServer) Routes() *chi.Mux {
+	r := chi.NewRouter()
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.NoCache)
+
+	r.Mount("/debug", middleware.Profiler())
+	r.Handle("/metrics", a.metrics.Handler())
+	r.Group(func(r chi.Router) {
+		r.Use(render.SetContentType(render.ContentTypeJSON))
+		r.Get("/runtime", a.handleRuntime)
+		r.Get("/db/stats", a.handleDBStats)
+		r.Get("/health", a.handleHealth)
+		r.Get("/config", a.handleConfig)
+		r.Get("/log/level", handleGetLogLevel)
+		r.Put("/log/level", handleSetLogLevel)
+	})
+
+	return r
+}
+
+func (a *AdminServer) handleRuntime(response http.ResponseWriter, request *http.Request) {
+	type runtimeStats struct {
+		GoVersion    string `json:"go_version"`
+		Uptime       string `json:"uptime"`
+		Goroutines   int    `json:"goroutines"`
+		GOMAXPROCS   int    `json:"gomaxprocs"`
+		NumCPU       int    `json:"num_cpu"`
+		HeapAlloc    uint64 `json:"heap_alloc_bytes"`
+		HeapInuse    uint64 `json:"heap_inuse_bytes"`
+		Sys          uint64 `json:"sys_bytes"`
+		NumGC        uint32 `json:"num_gc"`
+		PauseTotalNs uint64 `json:"gc_pause_total_ns"`
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	render.JSON(response, request, &runtimeStats{
+		GoVersion:    runtime.Version(),
+		Uptime:       time.Since(a.startedAt).Round(time.Second).String(),
+		Goroutines:   runtime.NumGoroutine(),
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		NumCPU:       runtime.NumCPU(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapInuse:    mem.HeapInuse,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		PauseTotalNs: mem.PauseTotalNs,
+	})
+}
+
+func (a *AdminServer) handleDBStats(response http.ResponseWriter, request *http.Request) {
+	type dbStats struct {
+		Enabled bool         `json:"enabled"`
+		Write   *sql.DBStats `json:"write,omitempty"`
+		Read    *sql.DBStats `json:"read,omitempty"`
+	}
+
+	data := &dbStats{}
+	if a.database != nil {
+		data.Enabled = true
+		if a.database.Pool != nil {
+			stats := a.database.Pool.Stats()
+			data.Write = &stats
+		}
+		if a.database.PoolRead != nil {
+			stats := a.database.PoolRead.Stats()
+			data.Read = &stats
+		}
+	}
+
+	render.JSON(response, request, data)
+}
+
+// handleHealth runs every registered check, whatever its probes, and always
+// answers 200 so the report can be read while the service is down.
+func (a *AdminServer) handleHealth(response http.ResponseWriter, request *http.Request) {
+	render.JSON(response, request, a.checks.Run(request.Context(), ""))
+}
+
+func (a *AdminServer) handleConfig(response http.ResponseWriter, request *http.Request) {
+	settings := a.settings()
+	if settings == nil {
+		render.Render(response, request, handlers.ErrNotFound)
+		return
+	}
+
+	render.JSON(response, request, settings.Redacted())
+}
+
+type logLevel struct {
+	Level string `json:"level"`
+}
+
+func (l *logLevel) Bind(r *http.Request) error {
+	if l.Level == "" {
+		return errors.New("level is required")
+	}
+	return nil
+}
+
+func handleGetLogLevel(response http.ResponseWriter, request *http.Request) {
+	render.JSON(response, request, &logLevel{Level: zerolog.GlobalLevel().String()})
+}
+
+// handleSetLogLevel changes the global zerolog level until the next restart.
+func handleSetLogLevel(response http.ResponseWriter, request *http.Request) {
+	var data logLevel
+	if err := render.Bind(request, &data); err != nil {
+		render.Render(response, request, handlers.ErrInvalidRequest(err))
+		return
+	}
+
+	level, err := zerolog.ParseLevel(data.Level)
+	if err != nil {
+		render.Render(response, request, handlers.ErrInvalidRequest(err))
+		return
+	}
+
+	log.Info().Msg(fmt.Sprintf("Changing log level from %s to %s", zerolog.GlobalLevel(), level))
+	zerolog.SetGlobalLevel(level)
+
+	render.JSON(response, request, &logLevel{Level: level.String()})
+}
@@ -5,7 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
+	"sync/atomic"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/go-chi/chi/v5"
@@ -14,15 +14,39 @@ import (
 	"github.com/go-chi/render"
 	"github.com/rs/zerolog/log"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
+
+	"template/apiserver/handlers"
+	"template/config"
+	"template/datastore/db/mysql"
+	"template/health"
+	"template/metrics"
+	"template/tracing"
 )
 
 type ApiServer struct {
-	awsCfg *aws.Config
+	awsCfg    *aws.Config
+	database  *mysql.DB
+	checks    *health.Registry
+	metrics   *metrics.Registry
+	accessLog *accessLog
+	ready     atomic.Bool
+	started   atomic.Bool
+	origins   atomic.Pointer[[]string]
+	routes    []func(r chi.Router)
+	versions  []*Version
 }
 
-func NewServer(awsCfg *aws.Config) *ApiServer {
+// NewServer creates the API server, its probes run the checks of the health
+// registry and its requests are measured in the metrics registry. A nil
+// database runs the server without persistence: the health check reports it
+// as disabled and routes wrapped with RequireDatabase answer 503.
+func NewServer(awsCfg *aws.Config, database *mysql.DB, checks *health.Registry, metrics *metrics.Registry) *ApiServer {
 	return &ApiServer{
-		awsCfg: awsCfg,
+		awsCfg:    awsCfg,
+		database:  database,
+		checks:    checks,
+		metrics:   metrics,
+		accessLog: newAccessLog(),
 	}
 }
 
@@ -31,11 +55,15 @@ const (
 	CSRFTokenHeaderName = "x-csrf-token"
 )
 
-func (a *ApiServer) SetupRoutes(envBaseUrl string, r *chi.Mux, port int, settings_cors_origins string) {
-	a.setupCORS(settings_cors_origins, r)
+// SetupRoutes mounts the routes under basePath, e.g. /api, and the versioned
+// routes under basePath/<version>. An empty basePath or / mounts them at the root.
+func (a *ApiServer) SetupRoutes(basePath string, r *chi.Mux, port int, cors_origins []string) error {
+	if err := a.setupCORS(cors_origins, r); err != nil {
+		return err
+	}
 	a.setupMiddleware(r)
 
-	envBaseUrl = fmt.Sprintf("/%s", envBaseUrl)
+	basePath = "/" + strings.Trim(basePath, "/")
 
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "web/index.html")
@@ -44,73 +72,119 @@ func (a *ApiServer) SetupRoutes(envBaseUrl string, r *chi.Mux, port int, setting
 	fs := http.FileServer(http.Dir("web"))
 	r.Get("/web/*", http.StripPrefix("/web", fs).ServeHTTP)
 
-	a.registerCommonAPI(envBaseUrl, r)
+	a.registerCommonAPI(strings.TrimSuffix(basePath, "/"), r)
+
+	mount := func(api chi.Router) {
+		for _, routes := range a.routes {
+			api.Group(routes)
+		}
+		for _, v := range a.versions {
+			if len(v.routes) == 0 {
+				log.Warn().Msg(fmt.Sprintf("API version %s has no routes", v.Name))
+				continue
+			}
+			v.mount(api)
+		}
+	}
+	switch {
+	case len(a.routes) == 0 && len(a.versions) == 0:
+	case basePath == "/":
+		r.Group(mount)
+	default:
+		r.Route(basePath, mount)
+	}
 
 	serveSwagger(r)
+
+	return nil
 }
 
-func (a *ApiServer) setupCORS(settings_cors_origins string, r *chi.Mux) {
-	cors_origins, err := LoadAllowedOrigins(settings_cors_origins)
-	if err != nil {
-		panic(err)
+func (a *ApiServer) setupCORS(cors_origins []string, r *chi.Mux) error {
+	if err := a.SetAllowedOrigins(cors_origins); err != nil {
+		return err
+	}
+	r.Use(cors.Handler(cors.Options{
+		AllowOriginFunc:  a.allowOrigin,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", CSRFTokenHeaderName, SessionHeaderName},
+		ExposedHeaders:   []string{"Link", CSRFTokenHeaderName, SessionHeaderName},
+		AllowCredentials: true,
+		MaxAge:           300, // Maximum value not ignored by any of major browsers
+	}))
+
+	return nil
+}
+
+// SetAllowedOrigins replaces the CORS allowed origins while the server runs.
+// Origins may hold one wildcard, e.g. https://*.example.com.
+func (a *ApiServer) SetAllowedOrigins(cors_origins []string) error {
+	if err := ValidateAllowedOrigins(cors_origins); err != nil {
+		return fmt.Errorf("invalid allowed origins: %w", err)
 	}
-	if len(cors_origins) > 0 {
-		log.Info().Msg(fmt.Sprintf("Cors Host: %s\n", cors_origins))
-		r.Use(cors.Handler(cors.Options{
-			AllowedOrigins:   cors_origins, // set allowed origins to all
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", CSRFTokenHeaderName, SessionHeaderName},
-			ExposedHeaders:   []string{"Link", CSRFTokenHeaderName, SessionHeaderName},
-			AllowCredentials: true,
-			MaxAge:           300, // Maximum value not ignored by any of major browsers
-		}))
+	log.Info().Msg(fmt.Sprintf("Cors Host: %s\n", cors_origins))
+	a.origins.Store(&cors_origins)
+	return nil
+}
+
+func (a *ApiServer) allowOrigin(_ *http.Request, origin string) bool {
+	origins := a.origins.Load()
+	if origins == nil {
+		return false
 	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range *origins {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if prefix, suffix, wildcard := strings.Cut(allowed, "*"); wildcard {
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		} else if origin == allowed {
+			return true
+		}
+	}
+	return false
 }
 
 // TODO - Move to API Server
 func (a *ApiServer) setupMiddleware(r *chi.Mux) {
 	r.Use(middleware.RequestID)
-	r.Use(middleware.Logger)
+	r.Use(tracing.Middleware)
+	r.Use(a.metrics.Middleware)
+	r.Use(a.accessLog.middleware)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.URLFormat)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 }
 
-func handleHealthCheck(response http.ResponseWriter, request *http.Request) {
-	type serverTime struct {
-		Message string `json:"message"`
-		Time    string `json:"time"`
-	}
-	now := time.Now()
-	data := &serverTime{
-		Time:    now.Format(time.RFC3339),
-		Message: "Systems Up",
-	}
-	render.JSON(response, request, data)
+// RequireDatabase rejects requests with a 503 when the server runs without a database.
+// Wrap every route group that needs persistence with it.
+func (a *ApiServer) RequireDatabase(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.database == nil {
+			render.Render(w, r, handlers.ErrServiceUnavailable(errors.New("this endpoint requires a database and the service is running without one")))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
-func (a *ApiServer) registerCommonAPI(envBaseUrl string, subrouter chi.Router) {
-	subrouter.Group(func(r chi.Router) {
-		r.Get(envBaseUrl+"/health", handleHealthCheck)
-	})
+// AddRoutes registers routes to mount under the base path, in a group of
+// their own so middlewares added by one set don't leak into the others.
+func (a *ApiServer) AddRoutes(routes func(r chi.Router)) {
+	a.routes = append(a.routes, routes)
 }
 
 func LoadAllowedOrigins(settings_origins string) ([]string, error) {
 	origins := strings.Split(settings_origins, ",")
 	err := ValidateAllowedOrigins(origins)
 	if err != nil {
-		panic(fmt.Sprintf("Invalid allowed origins: %v", err))
+		return nil, fmt.Errorf("invalid allowed origins: %w", err)
 	}
 	return origins, nil
 }
 
 func ValidateAllowedOrigins(origins []string) error {
-	for _, origin := range origins {
-		if origin == "*" {
-			return errors.New("wildcard '*' is not allowed in CORS allowed origins")
-		}
-	}
-	return nil
+	return config.ValidateAllowedOrigins(origins)
 }
 
 func getSpecs() map[string]string {
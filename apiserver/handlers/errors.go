@@ -65,6 +65,29 @@ func ErrNotAuthorized(err error) render.Renderer {
 	}
 }
 
+func ErrServiceUnavailable(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: 503,
+
+		StatusCode: 503,
+		StatusText: "Service unavailable.",
+		ErrorText:  err.Error(),
+	}
+}
+
+// ErrResourceNotFound answers a request for a record that does not exist.
+func ErrResourceNotFound(err error) render.Renderer {
+	return &ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusNotFound,
+
+		StatusCode: http.StatusNotFound,
+		StatusText: "Resource not found.",
+		ErrorText:  err.Error(),
+	}
+}
+
 var ErrNotFound = &ErrResponse{HTTPStatusCode: 404, StatusText: "Resource not found."}
 
 // var ErrNotAuthorized = &ErrResponse{HTTPStatusCode: 401, StatusText: "Not authorized.", ErrorText: "Invalid credentials."}
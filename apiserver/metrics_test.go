@@ -0,0 +1,36 @@
+package apiserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"template/health"
+	"template/metrics"
+)
+
+func TestMetricsOnAdminServerOnly(t *testing.T) {
+	registry := metrics.NewRegistry()
+
+	public := chi.NewRouter()
+	if err := NewServer(nil, nil, health.NewRegistry(), registry).SetupRoutes("/api", public, 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	admin := NewAdminServer(nil, nil, health.NewRegistry(), registry).Routes()
+
+	for name, tt := range map[string]struct {
+		router http.Handler
+		want   int
+	}{
+		"public": {public, http.StatusNotFound},
+		"admin":  {admin, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		tt.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s GET /metrics = %d, want %d", name, w.Code, tt.want)
+		}
+	}
+}
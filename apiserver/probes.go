@@ -0,0 +1,96 @@
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"template/health"
+)
+
+// SetReady flips the readiness probe. It is set once the listener is up and
+// cleared when the server starts draining so load balancers stop routing to
+// it. The startup probe passes from the first time it is set.
+func (a *ApiServer) SetReady(ready bool) {
+	a.ready.Store(ready)
+	if ready {
+		a.started.Store(true)
+	}
+}
+
+type probeResponse struct {
+	Status   string          `json:"status"`
+	Message  string          `json:"message"`
+	Time     string          `json:"time"`
+	Database string          `json:"database,omitempty"`
+	Checks   []health.Result `json:"checks"`
+}
+
+// probe answers with the checks of the probe, every check when empty, and a
+// 503 when one of them or the gate fails.
+func (a *ApiServer) probe(probe health.Probe, gate func() (bool, string)) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		report := health.Report{Status: health.StatusUp, Checks: []health.Result{}}
+		if a.checks != nil {
+			report = a.checks.Run(request.Context(), probe)
+		}
+
+		data := &probeResponse{
+			Status:  report.Status,
+			Message: "Systems Up",
+			Time:    time.Now().Format(time.RFC3339),
+			Checks:  report.Checks,
+		}
+		if !report.Up() {
+			data.Message = "Systems Down"
+		}
+		if gate != nil {
+			if ok, message := gate(); !ok {
+				data.Status = health.StatusDown
+				data.Message = message
+			}
+		}
+		if probe == "" {
+			data.Database = "enabled"
+			if a.database == nil {
+				data.Database = "disabled"
+			}
+		}
+
+		if data.Status != health.StatusUp {
+			render.Status(request, http.StatusServiceUnavailable)
+		}
+		render.JSON(response, request, data)
+	}
+}
+
+func (a *ApiServer) readyGate() (bool, string) {
+	return a.ready.Load(), "Not Ready"
+}
+
+func (a *ApiServer) startedGate() (bool, string) {
+	return a.started.Load(), "Starting"
+}
+
+// registerCommonAPI serves the probes: /health runs every check, /health/live,
+// /health/ready and /health/startup those of their probe. /health/ready also
+// fails while draining on shutdown. Their access log lines are sampled.
+func (a *ApiServer) registerCommonAPI(basePath string, subrouter chi.Router) {
+	probes := []struct {
+		path    string
+		handler http.HandlerFunc
+	}{
+		{"/health", a.probe("", nil)},
+		{"/health/live", a.probe(health.Liveness, nil)},
+		{"/health/ready", a.probe(health.Readiness, a.readyGate)},
+		{"/health/startup", a.probe(health.Startup, a.startedGate)},
+	}
+	subrouter.Group(func(r chi.Router) {
+		for _, p := range probes {
+			r.Get(basePath+p.path, p.handler)
+			a.accessLog.probes[basePath+p.path] = true
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package apiserver
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// RouteSnapshot is a stable description of a router, meant to be committed and
+// compared with DiffRoutes to catch accidental API changes in review.
+type RouteSnapshot struct {
+	Routes []RouteEntry `json:"routes"`
+}
+
+type RouteEntry struct {
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Middlewares []string `json:"middlewares"`
+}
+
+func (e RouteEntry) key() string {
+	return e.Method + " " + e.Pattern
+}
+
+// SnapshotRoutes walks the router and records every method, pattern and the
+// middlewares applied to it, sorted by pattern then method.
+func SnapshotRoutes(r chi.Routes) (RouteSnapshot, error) {
+	snapshot := RouteSnapshot{Routes: []RouteEntry{}}
+
+	err := chi.Walk(r, func(method, route string, _ http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		names := make([]string, 0, len(middlewares))
+		for _, mw := range middlewares {
+			names = append(names, funcName(mw))
+		}
+
+		snapshot.Routes = append(snapshot.Routes, RouteEntry{
+			Method:      method,
+			Pattern:     strings.Replace(route, "/*/", "/", -1),
+			Middlewares: names,
+		})
+		return nil
+	})
+	if err != nil {
+		return RouteSnapshot{}, err
+	}
+
+	sort.Slice(snapshot.Routes, func(i, j int) bool {
+		if snapshot.Routes[i].Pattern != snapshot.Routes[j].Pattern {
+			return snapshot.Routes[i].Pattern < snapshot.Routes[j].Pattern
+		}
+		return snapshot.Routes[i].Method < snapshot.Routes[j].Method
+	})
+
+	return snapshot, nil
+}
+
+func funcName(fn interface{}) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "unknown"
+	}
+	return f.Name()
+}
+
+// RouteDiff lists the differences between a committed snapshot and the current routes.
+type RouteDiff struct {
+	Added   []RouteEntry
+	Removed []RouteEntry
+	Changed []RouteChange
+}
+
+type RouteChange struct {
+	Before RouteEntry
+	After  RouteEntry
+}
+
+// Breaking reports whether routes were removed or changed. Added routes are not breaking.
+func (d RouteDiff) Breaking() bool {
+	return len(d.Removed) > 0 || len(d.Changed) > 0
+}
+
+func (d RouteDiff) String() string {
+	var b strings.Builder
+	for _, e := range d.Removed {
+		fmt.Fprintf(&b, "- removed %s\n", e.key())
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(&b, "~ changed %s: middlewares [%s] -> [%s]\n", c.After.key(),
+			strings.Join(c.Before.Middlewares, ", "), strings.Join(c.After.Middlewares, ", "))
+	}
+	for _, e := range d.Added {
+		fmt.Fprintf(&b, "+ added %s\n", e.key())
+	}
+	return b.String()
+}
+
+func DiffRoutes(before, after RouteSnapshot) RouteDiff {
+	var diff RouteDiff
+
+	current := make(map[string]RouteEntry, len(after.Routes))
+	for _, e := range after.Routes {
+		current[e.key()] = e
+	}
+
+	previous := make(map[string]struct{}, len(before.Routes))
+	for _, old := range before.Routes {
+		previous[old.key()] = struct{}{}
+
+		now, ok := current[old.key()]
+		switch {
+		case !ok:
+			diff.Removed = append(diff.Removed, old)
+		case !slices.Equal(old.Middlewares, now.Middlewares):
+			diff.Changed = append(diff.Changed, RouteChange{Before: old, After: now})
+		}
+	}
+
+	for _, e := range after.Routes {
+		if _, ok := previous[e.key()]; !ok {
+			diff.Added = append(diff.Added, e)
+		}
+	}
+
+	return diff
+}
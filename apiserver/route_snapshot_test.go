@@ -0,0 +1,87 @@
+package apiserver
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	list := RouteEntry{Method: "GET", Pattern: "/v1/users", Middlewares: []string{"auth"}}
+	create := RouteEntry{Method: "POST", Pattern: "/v1/users", Middlewares: []string{"auth"}}
+	health := RouteEntry{Method: "GET", Pattern: "/health", Middlewares: []string{}}
+
+	tests := []struct {
+		name         string
+		before       []RouteEntry
+		after        []RouteEntry
+		want         RouteDiff
+		wantBreaking bool
+	}{
+		{
+			name:   "unchanged",
+			before: []RouteEntry{health, list},
+			after:  []RouteEntry{health, list},
+		},
+		{
+			name:   "added",
+			before: []RouteEntry{list},
+			after:  []RouteEntry{list, create},
+			want:   RouteDiff{Added: []RouteEntry{create}},
+		},
+		{
+			name:         "removed",
+			before:       []RouteEntry{list, create},
+			after:        []RouteEntry{list},
+			want:         RouteDiff{Removed: []RouteEntry{create}},
+			wantBreaking: true,
+		},
+		{
+			name:   "middlewares changed",
+			before: []RouteEntry{list},
+			after:  []RouteEntry{{Method: "GET", Pattern: "/v1/users", Middlewares: []string{"auth", "deprecation"}}},
+			want: RouteDiff{Changed: []RouteChange{{
+				Before: list,
+				After:  RouteEntry{Method: "GET", Pattern: "/v1/users", Middlewares: []string{"auth", "deprecation"}},
+			}}},
+			wantBreaking: true,
+		},
+		{
+			name:         "method changed",
+			before:       []RouteEntry{create},
+			after:        []RouteEntry{{Method: "PUT", Pattern: "/v1/users", Middlewares: []string{"auth"}}},
+			want:         RouteDiff{Added: []RouteEntry{{Method: "PUT", Pattern: "/v1/users", Middlewares: []string{"auth"}}}, Removed: []RouteEntry{create}},
+			wantBreaking: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffRoutes(RouteSnapshot{Routes: tt.before}, RouteSnapshot{Routes: tt.after})
+
+			if !reflect.DeepEqual(diff, tt.want) {
+				t.Errorf("diff = %+v, want %+v", diff, tt.want)
+			}
+			if got := diff.Breaking(); got != tt.wantBreaking {
+				t.Errorf("Breaking() = %v, want %v", got, tt.wantBreaking)
+			}
+		})
+	}
+}
+
+func TestRouteDiffString(t *testing.T) {
+	diff := RouteDiff{
+		Added:   []RouteEntry{{Method: "POST", Pattern: "/v1/users"}},
+		Removed: []RouteEntry{{Method: "DELETE", Pattern: "/v1/users/{id}"}},
+		Changed: []RouteChange{{
+			Before: RouteEntry{Method: "GET", Pattern: "/v1/users", Middlewares: []string{"auth"}},
+			After:  RouteEntry{Method: "GET", Pattern: "/v1/users", Middlewares: []string{"auth", "deprecation"}},
+		}},
+	}
+
+	want := "- removed DELETE /v1/users/{id}\n" +
+		"~ changed GET /v1/users: middlewares [auth] -> [auth, deprecation]\n" +
+		"+ added POST /v1/users\n"
+	if got := diff.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
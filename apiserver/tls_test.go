@@ -0,0 +1,25 @@
+package apiserver
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCertReloaderWatchDisabled(t *testing.T) {
+	reloader := &CertReloader{}
+
+	for _, interval := range []time.Duration{0, -time.Second} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			reloader.Watch(context.Background(), interval)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("Watch(%s) did not return", interval)
+		}
+	}
+}
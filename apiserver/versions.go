@@ -0,0 +1,77 @@
+package apiserver
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Version is a group of routes mounted at <base path>/<name>, e.g. /api/v2.
+type Version struct {
+	Name       string
+	Deprecated bool
+	// Sunset is when a deprecated version stops being served, zero when unknown.
+	Sunset time.Time
+
+	routes []func(r chi.Router)
+}
+
+// version returns the version of the name, registering it the first time.
+func (a *ApiServer) version(name string) *Version {
+	for _, v := range a.versions {
+		if v.Name == name {
+			return v
+		}
+	}
+	v := &Version{Name: name}
+	a.versions = append(a.versions, v)
+	return v
+}
+
+// AddVersionedRoutes registers routes to mount under each of the versions,
+// so one set of handlers can serve several versions of the API.
+func (a *ApiServer) AddVersionedRoutes(routes func(r chi.Router), versions ...string) {
+	for _, name := range versions {
+		v := a.version(name)
+		v.routes = append(v.routes, routes)
+	}
+}
+
+// DeprecateVersion marks a version as deprecated. Its responses carry the
+// Deprecation header, and the Sunset header when sunset isn't zero.
+func (a *ApiServer) DeprecateVersion(name string, sunset time.Time) {
+	v := a.version(name)
+	v.Deprecated = true
+	v.Sunset = sunset
+}
+
+// Versions returns the registered versions in registration order.
+func (a *ApiServer) Versions() []Version {
+	versions := make([]Version, 0, len(a.versions))
+	for _, v := range a.versions {
+		versions = append(versions, *v)
+	}
+	return versions
+}
+
+func (v *Version) mount(api chi.Router) {
+	api.Route("/"+v.Name, func(r chi.Router) {
+		if v.Deprecated {
+			r.Use(v.deprecation)
+		}
+		for _, routes := range v.routes {
+			r.Group(routes)
+		}
+	})
+}
+
+func (v *Version) deprecation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		if !v.Sunset.IsZero() {
+			w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rs/zerolog/log"
+
+	"template/config"
+	"template/datastore/db/mysql"
+	"template/scaffold"
+)
+
+// Commands parse into their own structs and copy them into the Starship on
+// Execute, since go-flags applies the defaults of every command, not only the
+// one that runs.
+type serveCommand struct {
+	Args
+	args     *Args
+	director *BuildDirector
+}
+
+func (c *serveCommand) Execute(_ []string) error {
+	if err := c.Args.validate(); err != nil {
+		return err
+	}
+	*c.args = c.Args
+	return c.director.BuildStarship()
+}
+
+// validate rejects the option values go-flags can parse but the server can't
+// use, as usage errors.
+func (a Args) validate() error {
+	if a.TLSReloadInterval < 0 {
+		return &flags.Error{
+			Type:    flags.ErrMarshal,
+			Message: fmt.Sprintf("--tls-reload-interval must not be negative, got %s", a.TLSReloadInterval),
+		}
+	}
+	return nil
+}
+
+type workerCommand struct {
+	RunArgs
+	WorkerArgs
+	args       *Args
+	workerArgs *WorkerArgs
+	director   *BuildDirector
+}
+
+func (c *workerCommand) Execute(_ []string) error {
+	c.args.RunArgs = c.RunArgs
+	*c.workerArgs = c.WorkerArgs
+	return c.director.BuildWorker()
+}
+
+type migrateOptions struct {
+	Module string `short:"m" long:"module" description:"Only run the migrations of this module ('core' for the built-in ones), required by down and redo once modules have migrations"`
+}
+
+type migrateCommand struct {
+	options  *migrateOptions
+	director *BuildDirector
+	command  string
+}
+
+func (c *migrateCommand) Execute(args []string) error {
+	return c.director.BuildMigrator(c.options.Module, c.command, args)
+}
+
+type migrateCreateCommand struct {
+	Positional struct {
+		Name string `positional-arg-name:"name" description:"Name of the new migration" required:"true"`
+	} `positional-args:"yes"`
+	director *BuildDirector
+}
+
+func (c *migrateCreateCommand) Execute(_ []string) error {
+	return c.director.BuildMigrator("", "create", []string{c.Positional.Name})
+}
+
+type seedCommand struct {
+	BucketName string `long:"bucket" description:"S3 bucket holding the seed data"`
+	Path       string `long:"path" description:"Path of the seed data inside the bucket"`
+	FileName   string `long:"file" description:"Seed data file name"`
+	director   *BuildDirector
+}
+
+func (c *seedCommand) Execute(_ []string) error {
+	return c.director.BuildSeeder(mysql.SeedDataStore{
+		BucketName: c.BucketName,
+		Path:       c.Path,
+		FileName:   c.FileName,
+	})
+}
+
+// RouteDocsOptions selects the outputs of the routes command. With none set the
+// JSON documentation is printed to stdout.
+type RouteDocsOptions struct {
+	JSON     string `long:"json" description:"Write the JSON route documentation to this file"`
+	Markdown string `long:"markdown" description:"Write the Markdown route documentation to this file"`
+	Snapshot string `long:"snapshot" description:"Write a route snapshot (methods, patterns, middlewares) to this file"`
+	Check    string `long:"check" description:"Compare the routes with this snapshot and fail when routes were removed or changed"`
+}
+
+type routesCommand struct {
+	RouteDocsOptions
+	director *BuildDirector
+}
+
+func (c *routesCommand) Execute(_ []string) error {
+	return c.director.BuildRouteDocs(c.RouteDocsOptions)
+}
+
+var errInvalidConfig = errors.New("the configuration is invalid")
+
+type configCommand struct {
+	director *BuildDirector
+	command  string
+}
+
+func (c *configCommand) Execute(_ []string) error {
+	err := c.director.BuildConfigInspector(c.command)
+	var problems *config.ValidationError
+	var stepErr *StepError
+	if errors.As(err, &problems) && errors.As(err, &stepErr) {
+		fmt.Fprintf(os.Stderr, "The configuration is invalid:\n%s", problems.List())
+		// Only the list above describes the problems.
+		return &StepError{Step: stepErr.Step, ExitCode: stepErr.ExitCode, Err: errInvalidConfig}
+	}
+	return err
+}
+
+type configGenerateCommand struct {
+	Env    string `long:"env" description:"Path of the generated dotenv template" default:".env.template"`
+	Schema string `long:"schema" description:"Path of the generated JSON Schema of the config file" default:"config.schema.json"`
+	Check  bool   `long:"check" description:"Fail when the files are not up to date instead of writing them"`
+}
+
+func (c *configGenerateCommand) Execute(_ []string) error {
+	schema, err := config.JSONSchema()
+	if err != nil {
+		return err
+	}
+
+	var stale []string
+	for _, file := range []struct {
+		path    string
+		content []byte
+	}{
+		{c.Env, config.EnvTemplate()},
+		{c.Schema, schema},
+	} {
+		if !c.Check {
+			if err := os.WriteFile(file.path, file.content, 0o644); err != nil {
+				return err
+			}
+			log.Info().Msg(fmt.Sprintf("Generated %s", file.path))
+			continue
+		}
+
+		current, err := os.ReadFile(file.path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if !bytes.Equal(current, file.content) {
+			stale = append(stale, file.path)
+		}
+	}
+
+	if len(stale) > 0 {
+		return fmt.Errorf("%s out of date with config.Settings, run go run . config generate", strings.Join(stale, " and "))
+	}
+	return nil
+}
+
+type generateResourceCommand struct {
+	Fields     string `short:"f" long:"fields" description:"Comma separated name:type fields, types are string, text, int, int64, float, float64, bool and time" required:"true"`
+	Positional struct {
+		Name string `positional-arg-name:"name" description:"Singular name of the resource, e.g. contact" required:"true"`
+	} `positional-args:"yes"`
+}
+
+func (c *generateResourceCommand) Execute(_ []string) error {
+	res, err := scaffold.NewResource(c.Positional.Name, c.Fields)
+	if err != nil {
+		return err
+	}
+
+	files, err := scaffold.Generate(".", res, time.Now())
+	for _, file := range files {
+		log.Info().Msg(fmt.Sprintf("Generated %s", file))
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info().Msg("Run go generate ./test/mocks to refresh the mocks")
+	return nil
+}
+
+// addCommands registers the CLI subcommands. Each one asks the director to build
+// only the parts of the Starship it needs.
+func addCommands(parser *flags.Parser, director *BuildDirector, args *Args, workerArgs *WorkerArgs) error {
+	if _, err := parser.AddCommand("serve", "Run the HTTP API",
+		"Loads the configuration, connects to the database and serves the HTTP API until a shutdown signal is received.",
+		&serveCommand{args: args, director: director}); err != nil {
+		return err
+	}
+
+	if _, err := parser.AddCommand("worker", "Run the background workers",
+		"Loads the configuration, connects to the database and runs the workers registered by the modules "+
+			"until a shutdown signal is received.",
+		&workerCommand{args: args, workerArgs: workerArgs, director: director}); err != nil {
+		return err
+	}
+
+	migrateOpts := &migrateOptions{}
+	migrate, err := parser.AddCommand("migrate", "Manage database migrations",
+		"Runs the embedded goose migrations and those of the registered modules against the configured database.", migrateOpts)
+	if err != nil {
+		return err
+	}
+	migrate.SubcommandsOptional = false
+
+	for _, sub := range []struct{ name, short string }{
+		{"up", "Apply all pending migrations"},
+		{"down", "Roll back the latest migration"},
+		{"status", "Print the status of all migrations"},
+		{"redo", "Roll back and re-apply the latest migration"},
+	} {
+		if _, err := migrate.AddCommand(sub.name, sub.short, sub.short, &migrateCommand{options: migrateOpts, director: director, command: sub.name}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := migrate.AddCommand("create", "Create a new SQL migration",
+		"Creates a new timestamped SQL migration in "+mysql.MigrationsSourceDir+".",
+		&migrateCreateCommand{director: director}); err != nil {
+		return err
+	}
+
+	if _, err := parser.AddCommand("seed", "Seed the database",
+		"Applies pending migrations and loads the seed data into the database.",
+		&seedCommand{director: director}); err != nil {
+		return err
+	}
+
+	if _, err := parser.AddCommand("routes", "Generate router documentation",
+		"Builds the router without starting the web server, writes its JSON and Markdown documentation "+
+			"and checks it against a committed snapshot.",
+		&routesCommand{director: director}); err != nil {
+		return err
+	}
+
+	generate, err := parser.AddCommand("generate", "Generate code",
+		"Generates code following the conventions of the repository. Run it from the repository root.", &struct{}{})
+	if err != nil {
+		return err
+	}
+	generate.SubcommandsOptional = false
+
+	if _, err := generate.AddCommand("resource", "Generate a CRUD resource",
+		"Generates the migration, model, MySQL and in-memory repositories, service, CRUD handlers and module "+
+			"of a resource, adds its mockgen directives and enables the module in main.go.",
+		&generateResourceCommand{}); err != nil {
+		return err
+	}
+
+	configCmd, err := parser.AddCommand("config", "Inspect the configuration",
+		"Loads the settings for the current mode exactly as serve does.", &struct{}{})
+	if err != nil {
+		return err
+	}
+	configCmd.SubcommandsOptional = false
+
+	if _, err := configCmd.AddCommand("print", "Print the effective settings",
+		"Prints the effective settings as JSON with the settings tagged secret masked.",
+		&configCommand{director: director, command: "print"}); err != nil {
+		return err
+	}
+	if _, err := configCmd.AddCommand("validate", "Validate the settings",
+		"Loads and validates the settings, listing every problem and exiting with code 3 when they are invalid. "+
+			"Use --mode to check the settings of each mode in CI.",
+		&configCommand{director: director, command: "validate"}); err != nil {
+		return err
+	}
+	if _, err := configCmd.AddCommand("generate", "Generate .env.template and the config file schema",
+		"Writes a dotenv template and a JSON Schema of the config file from the tags of config.Settings. "+
+			"With --check it fails when the committed files are stale instead.",
+		&configGenerateCommand{}); err != nil {
+		return err
+	}
+	if _, err := configCmd.AddCommand("provenance", "Print the source of every setting",
+		"Prints which source supplied each setting: default, unset, the --config file, the .env file, "+
+			"the environment, the secret or the --set flags.",
+		&configCommand{director: director, command: "provenance"}); err != nil {
+		return err
+	}
+
+	return nil
+}
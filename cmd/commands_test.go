@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestArgsValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		interval time.Duration
+		wantCode int
+	}{
+		{name: "reload", interval: time.Minute},
+		{name: "reload disabled", interval: 0},
+		{name: "negative", interval: -time.Second, wantCode: ExitUsage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Args{TLSReloadInterval: tt.interval}.validate()
+
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("validate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("validate accepted the interval")
+			}
+			if got := exitCode(err); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
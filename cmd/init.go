@@ -1,8 +1,40 @@
 package cmd
 
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rs/zerolog/log"
+)
+
 func StartApp() {
 	star := NewStarship()
 	buildDirector := NewStarshipBuilder(star)
 
-	buildDirector.BuildStarship()
+	parser := flags.NewParser(nil, flags.HelpFlag|flags.PassDoubleDash)
+	if _, err := parser.AddGroup("Configuration Options", "", &star.configArgs); err != nil {
+		log.Error().Err(err).Msg("failed to register options")
+		os.Exit(ExitFailure)
+	}
+	if err := addCommands(parser, buildDirector, &star.args, &star.workerArgs); err != nil {
+		log.Error().Err(err).Msg("failed to register commands")
+		os.Exit(ExitFailure)
+	}
+
+	if _, err := parser.Parse(); err != nil {
+		var flagsErr *flags.Error
+		switch {
+		case flags.WroteHelp(err):
+			fmt.Fprintln(os.Stdout, err)
+			return
+		case errors.As(err, &flagsErr):
+			fmt.Fprintln(os.Stderr, err)
+		default:
+			log.Error().Err(err).Msg("command failed")
+		}
+
+		os.Exit(exitCode(err))
+	}
 }
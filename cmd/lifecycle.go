@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ShutdownHook releases a resource owned by the Starship when the process stops.
+type ShutdownHook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// Lifecycle keeps the shutdown hooks registered while the Starship is built.
+// Hooks run in reverse registration order, so resources created late (the web
+// server, workers) are stopped before the ones they depend on (the database).
+type Lifecycle struct {
+	mu    sync.Mutex
+	hooks []namedHook
+	done  bool
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// OnShutdown registers a hook to run when Shutdown is called.
+func (l *Lifecycle) OnShutdown(name string, hook ShutdownHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.hooks = append(l.hooks, namedHook{name: name, hook: hook})
+}
+
+// Shutdown runs every registered hook once, even if some of them fail, and
+// returns the joined errors.
+func (l *Lifecycle) Shutdown(ctx context.Context) error {
+	l.mu.Lock()
+	if l.done {
+		l.mu.Unlock()
+		return nil
+	}
+	l.done = true
+	hooks := l.hooks
+	l.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		log.Info().Msg(fmt.Sprintf("Shutting down %s...", h.name))
+		if err := h.hook(ctx); err != nil {
+			log.Error().Err(err).Msg(fmt.Sprintf("failed to shut down %s", h.name))
+			errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
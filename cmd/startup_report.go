@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/rs/zerolog/log"
+
+	"template/config"
+)
+
+// Process exit codes, so deploy tooling can tell failures apart.
+const (
+	ExitFailure  = 1
+	ExitUsage    = 2
+	ExitConfig   = 3
+	ExitDatabase = 4
+	ExitServer   = 5
+)
+
+// StepError is returned by the BuildDirector when a build step fails.
+type StepError struct {
+	Step     string
+	ExitCode int
+	Err      error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode maps an error returned by the CLI to the process exit code.
+func exitCode(err error) int {
+	var stepErr *StepError
+	if errors.As(err, &stepErr) {
+		return stepErr.ExitCode
+	}
+
+	var flagsErr *flags.Error
+	if errors.As(err, &flagsErr) {
+		return ExitUsage
+	}
+
+	return ExitFailure
+}
+
+type StepReport struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+// DependencyCheck records an external dependency a build step reached out to.
+type DependencyCheck struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+func newDependencyCheck(name string, started time.Time, err error) DependencyCheck {
+	check := DependencyCheck{
+		Name:     name,
+		OK:       err == nil,
+		Duration: time.Since(started).String(),
+	}
+	if err != nil {
+		check.Error = err.Error()
+	}
+
+	return check
+}
+
+// StartupReport describes how the Starship was built: the steps that ran, how
+// long each took, which one failed and the dependencies that were checked.
+type StartupReport struct {
+	Steps        []StepReport      `json:"steps"`
+	Dependencies []DependencyCheck `json:"dependencies"`
+	FailedStep   string            `json:"failed_step,omitempty"`
+	Duration     string            `json:"duration"`
+
+	started time.Time
+}
+
+func newStartupReport() *StartupReport {
+	return &StartupReport{
+		Steps:        []StepReport{},
+		Dependencies: []DependencyCheck{},
+		started:      time.Now(),
+	}
+}
+
+func (r *StartupReport) addStep(name string, duration time.Duration, err error) {
+	step := StepReport{
+		Name:     name,
+		Duration: duration.String(),
+	}
+	if err != nil {
+		step.Error = err.Error()
+		// The command prints or logs the problems of invalid settings once.
+		var problems *config.ValidationError
+		if errors.As(err, &problems) {
+			keys := make([]string, 0, len(problems.Problems))
+			for _, p := range problems.Problems {
+				keys = append(keys, p.Key)
+			}
+			step.Error = "invalid settings: " + strings.Join(keys, ", ")
+		}
+		r.FailedStep = name
+	}
+
+	r.Steps = append(r.Steps, step)
+}
+
+func (r *StartupReport) log() {
+	r.Duration = time.Since(r.started).String()
+
+	if r.FailedStep != "" {
+		log.Error().Interface("startup_report", r).Msg(fmt.Sprintf("Startup failed at step %s", r.FailedStep))
+		return
+	}
+
+	log.Info().Interface("startup_report", r).Msg("Startup complete")
+}
@@ -2,16 +2,25 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/docgen"
-	"github.com/jessevdk/go-flags"
-	"github.com/joho/godotenv"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	_ "github.com/swaggo/http-swagger/example/go-chi/docs"
@@ -19,120 +28,1098 @@ import (
 	"template/apiserver"
 	"template/config"
 	"template/datastore/db/mysql"
+	"template/datastore/db/mysql/repositories"
+	"template/datastore/memory"
+	"template/health"
+	"template/metrics"
+	"template/modules"
 	awsUtils "template/pkg"
+	"template/tracing"
+	"template/worker"
 )
 
 const (
 	localEnv         = "local"
 	awsRegionEnv     = "aws_region"
 	awsRegionDefault = "us-west-2"
+	secretsProvider  = config.EnvSecretsProvider
+	secretNames      = config.EnvSecretNames
+	databaseNone     = "none"
+	coreRepositories = "core.repositories"
 )
 
+// RunArgs are shared by the long running commands, serve and worker.
+type RunArgs struct {
+	Database       string `short:"d" long:"database" description:"Database backend, 'none' runs without MySQL using in-memory repositories" choice:"mysql" choice:"none" default:"mysql"`
+	SkipMigrations bool   `long:"skip-migrations" description:"Do not apply pending migrations on startup"`
+
+	ShutdownTimeout time.Duration `long:"shutdown-timeout" description:"How long to wait for in-flight work and shutdown hooks before exiting" default:"15s"`
+
+	ConfigReloadInterval time.Duration `long:"config-reload-interval" description:"How often to reload the config file, dotenv files and secrets and apply changed database credentials and CORS origins. Disabled when 0" default:"0s"`
+
+	AdminAddress string `long:"admin-address" description:"Serve pprof, runtime and pool stats, the redacted settings and the log level on this address, e.g. 127.0.0.1:6060. Disabled when empty"`
+}
+
 type Args struct {
-	Address  string `short:"a" long:"address" description:"The address to listen on for HTTP requests" default:"0.0.0.0"`
-	Port     int    `short:"p" long:"port" description:"The port to listen on for HTTP requests" default:"3333"`
-	Routes   bool   `short:"r" long:"routes" description:"Generate router documentation"`
-	Database bool   `short:"d" long:"database" description:"Use a database"`
+	RunArgs
+
+	Address string `short:"a" long:"address" description:"The address to listen on for HTTP requests" default:"0.0.0.0"`
+	Port    int    `short:"p" long:"port" description:"The port to listen on for HTTP requests" default:"3333"`
+
+	TLS               bool          `long:"tls" description:"Serve HTTPS, with a generated self-signed certificate in local mode unless --tls-cert and --tls-key are set"`
+	TLSCert           string        `long:"tls-cert" description:"Path to the PEM encoded TLS certificate, implies --tls"`
+	TLSKey            string        `long:"tls-key" description:"Path to the PEM encoded TLS private key"`
+	TLSReloadInterval time.Duration `long:"tls-reload-interval" description:"How often to check the certificate files for rotation. Disabled when 0" default:"1m"`
+
+	ShutdownDelay time.Duration `long:"shutdown-delay" description:"How long to report not ready before the listener stops accepting connections" default:"0s"`
+}
+
+// ConfigArgs are global options layered over the other configuration sources.
+type ConfigArgs struct {
+	Mode       string   `long:"mode" env:"mode" description:"Mode to run in, selects the .env.<mode> file and the default secret. Read from .env when unset, local otherwise"`
+	ConfigFile string   `long:"config" env:"config_file" description:"YAML or JSON settings file, layered under the dotenv files and the environment. Read from the dotenv files when unset"`
+	Settings   []string `long:"set" description:"Override a setting, e.g. --set db_max_conns=10, can be repeated"`
+}
+
+type WorkerArgs struct {
+	Concurrency int      `short:"c" long:"concurrency" description:"Concurrency of the workers that don't set their own" default:"1"`
+	Only        []string `long:"only" description:"Only run the named workers, can be repeated"`
 }
 
 type Starship struct {
-	args        Args
-	s3Utils     awsUtils.S3Utils
-	awsCfg      aws.Config
-	settingsMap *config.Settings
-	mode        string
-	Database    mysql.DB
+	args         Args
+	configArgs   ConfigArgs
+	workerArgs   WorkerArgs
+	s3Utils      awsUtils.S3Utils
+	awsCfg       aws.Config
+	settingsMap  *config.Settings
+	provenance   config.Provenance
+	watcher      *config.Watcher
+	mode         string
+	Database     mysql.DB
+	Repositories repositories.Repositories
+	modules      []modules.Module
+	deps         *modules.Deps
+	lifecycle    *Lifecycle
+	health       *health.Registry
+	metrics      *metrics.Registry
+	checks       []DependencyCheck
+	webServer    *apiserver.ApiServer
+	server       *http.Server
+	listener     net.Listener
+	workers      *worker.Runner
 }
 
 func NewStarship() *Starship {
-	return &Starship{}
+	return &Starship{
+		lifecycle: NewLifecycle(),
+		health:    health.NewRegistry(),
+		metrics:   metrics.NewRegistry(),
+	}
 }
 
 type StarshipBuilder interface {
-	setConfig()
-	setDatabase()
-	setRepositories()
-	setServices()
-	setWebServer()
+	databaseEnabled() bool
+	dependencyChecks() []DependencyCheck
+	setConfig(sections []string) error
+	setModules(ordered []modules.Module)
+	setTracing() error
+	setDatabase() error
+	setRepositories() error
+	setServices() error
+	startModules() error
+	setWebServer() error
+	serve() error
+	setWorkers() error
+	setHealthChecks() error
+	setAdminServer() error
+	watchConfig() error
+	runWorkers() error
+	setRouteDocs(opts RouteDocsOptions) error
+	runMigrations(source, command string, args []string) error
+	runSeeder(dataStore mysql.SeedDataStore) error
+	inspectConfig(command string) error
+	shutdown()
 }
 
 type BuildDirector struct {
-	builder StarshipBuilder
+	builder  StarshipBuilder
+	registry *modules.Registry
+	report   *StartupReport
 }
 
 func NewStarshipBuilder(sb StarshipBuilder) *BuildDirector {
 	return &BuildDirector{
-		builder: sb,
+		builder:  sb,
+		registry: modules.DefaultRegistry,
+		report:   newStartupReport(),
+	}
+}
+
+// buildStep is one step of a build, a failing step maps to exitCode.
+type buildStep struct {
+	name     string
+	exitCode int
+	run      func() error
+}
+
+// build runs the steps in order and stops at the first failure. Every build
+// ends with a startup report listing the steps and the dependencies checked.
+func (sbd *BuildDirector) build(steps ...buildStep) error {
+	defer sbd.report.log()
+
+	for _, step := range steps {
+		started := time.Now()
+		err := step.run()
+		sbd.report.addStep(step.name, time.Since(started), err)
+		sbd.report.Dependencies = append([]DependencyCheck{}, sbd.builder.dependencyChecks()...)
+
+		if err != nil {
+			return &StepError{Step: step.name, ExitCode: step.exitCode, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// configStep loads the settings, requiring those of the sections the command uses.
+func (sbd *BuildDirector) configStep(sections ...string) buildStep {
+	return buildStep{"config", ExitConfig, func() error {
+		return sbd.builder.setConfig(sections)
+	}}
+}
+
+// databaseSections requires the database settings unless running without one.
+func (sbd *BuildDirector) databaseSections(sections ...string) []string {
+	if sbd.builder.databaseEnabled() {
+		sections = append(sections, config.SectionDatabase)
+	}
+	return sections
+}
+
+// modulesStep orders the registered modules by their dependencies.
+func (sbd *BuildDirector) modulesStep() buildStep {
+	return buildStep{"modules", ExitFailure, func() error {
+		ordered, err := sbd.registry.Resolve()
+		if err != nil {
+			return err
+		}
+		sbd.builder.setModules(ordered)
+		return nil
+	}}
+}
+
+func (sbd *BuildDirector) BuildStarship() error {
+	defer sbd.builder.shutdown()
+
+	steps := []buildStep{
+		sbd.configStep(sbd.databaseSections(config.SectionHTTP)...),
+		sbd.modulesStep(),
+		{"tracing", ExitConfig, sbd.builder.setTracing},
+	}
+	if sbd.builder.databaseEnabled() {
+		steps = append(steps, buildStep{"database", ExitDatabase, sbd.builder.setDatabase})
+	}
+	steps = append(steps,
+		buildStep{"repositories", ExitFailure, sbd.builder.setRepositories},
+		buildStep{"services", ExitFailure, sbd.builder.setServices},
+		buildStep{"start modules", ExitFailure, sbd.builder.startModules},
+		buildStep{"health checks", ExitFailure, sbd.builder.setHealthChecks},
+		buildStep{"admin server", ExitServer, sbd.builder.setAdminServer},
+		buildStep{"web server", ExitServer, sbd.builder.setWebServer},
+		buildStep{"config watcher", ExitConfig, sbd.builder.watchConfig},
+	)
+
+	if err := sbd.build(steps...); err != nil {
+		return err
 	}
+
+	if err := sbd.builder.serve(); err != nil {
+		return &StepError{Step: "serve", ExitCode: ExitServer, Err: err}
+	}
+
+	return nil
+}
+
+// BuildWorker builds everything BuildStarship does but runs the background
+// workers registered by the modules instead of the web server.
+func (sbd *BuildDirector) BuildWorker() error {
+	defer sbd.builder.shutdown()
+
+	steps := []buildStep{
+		sbd.configStep(sbd.databaseSections()...),
+		sbd.modulesStep(),
+		{"tracing", ExitConfig, sbd.builder.setTracing},
+	}
+	if sbd.builder.databaseEnabled() {
+		steps = append(steps, buildStep{"database", ExitDatabase, sbd.builder.setDatabase})
+	}
+	steps = append(steps,
+		buildStep{"repositories", ExitFailure, sbd.builder.setRepositories},
+		buildStep{"services", ExitFailure, sbd.builder.setServices},
+		buildStep{"start modules", ExitFailure, sbd.builder.startModules},
+		buildStep{"health checks", ExitFailure, sbd.builder.setHealthChecks},
+		buildStep{"admin server", ExitServer, sbd.builder.setAdminServer},
+		buildStep{"workers", ExitServer, sbd.builder.setWorkers},
+		buildStep{"config watcher", ExitConfig, sbd.builder.watchConfig},
+	)
+
+	if err := sbd.build(steps...); err != nil {
+		return err
+	}
+
+	if err := sbd.builder.runWorkers(); err != nil {
+		return &StepError{Step: "run workers", ExitCode: ExitServer, Err: err}
+	}
+
+	return nil
+}
+
+// BuildMigrator loads the configuration and runs a migration command without
+// connecting the pools or booting the web server.
+func (sbd *BuildDirector) BuildMigrator(source, command string, args []string) error {
+	migrate := buildStep{"migrations", ExitDatabase, func() error {
+		return sbd.builder.runMigrations(source, command, args)
+	}}
+	if command == "create" {
+		return sbd.build(migrate)
+	}
+
+	return sbd.build(sbd.configStep(config.SectionDatabase), sbd.modulesStep(), migrate)
+}
+
+// BuildSeeder connects to the database and loads the seed data.
+func (sbd *BuildDirector) BuildSeeder(dataStore mysql.SeedDataStore) error {
+	defer sbd.builder.shutdown()
+
+	return sbd.build(
+		sbd.configStep(config.SectionDatabase),
+		sbd.modulesStep(),
+		buildStep{"database", ExitDatabase, sbd.builder.setDatabase},
+		buildStep{"seed", ExitDatabase, func() error {
+			return sbd.builder.runSeeder(dataStore)
+		}},
+	)
 }
 
-func (sbd *BuildDirector) BuildStarship() {
-	sbd.builder.setConfig()
-	sbd.builder.setDatabase()
-	sbd.builder.setRepositories()
-	sbd.builder.setServices()
-	sbd.builder.setWebServer()
+// BuildRouteDocs builds the router without a database and documents or checks it.
+func (sbd *BuildDirector) BuildRouteDocs(opts RouteDocsOptions) error {
+	return sbd.build(
+		sbd.configStep(config.SectionHTTP),
+		sbd.modulesStep(),
+		buildStep{"repositories", ExitFailure, sbd.builder.setRepositories},
+		buildStep{"services", ExitFailure, sbd.builder.setServices},
+		buildStep{"routes", ExitFailure, func() error {
+			return sbd.builder.setRouteDocs(opts)
+		}},
+	)
 }
 
-func (star *Starship) setConfig() {
+// BuildConfigInspector loads the configuration and prints or validates it.
+// The provenance is printed even when the settings are invalid, it tells where
+// the invalid values came from.
+func (sbd *BuildDirector) BuildConfigInspector(command string) error {
+	err := sbd.build(
+		sbd.configStep(config.Sections()...),
+		buildStep{"config " + command, ExitConfig, func() error {
+			return sbd.builder.inspectConfig(command)
+		}},
+	)
+
+	var problems *config.ValidationError
+	if command == "provenance" && errors.As(err, &problems) {
+		if err := sbd.builder.inspectConfig(command); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (star *Starship) dependencyChecks() []DependencyCheck {
+	return star.checks
+}
+
+// check records a dependency the build reached out to.
+func (star *Starship) check(name string, started time.Time, err error) error {
+	star.checks = append(star.checks, newDependencyCheck(name, started, err))
+	return err
+}
+
+// setConfig loads the settings from their sources, lowest precedence first:
+// the default tags, the --config file, the dotenv files, the process environment,
+// the secrets and the --set flags. Only the required settings of the given
+// sections must be set.
+func (star *Starship) setConfig(sections []string) error {
+	// The AWS calls are traced from the first client, the secret provider's,
+	// setTracing then exports their spans.
 	var awsCfg aws.Config
+	tracing.InstrumentAWS(&awsCfg)
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 
-	parser := flags.NewParser(&star.args, flags.Default)
-	_, err := parser.Parse()
-	if err != nil {
-		log.Err(err)
-		return
+	// Snapshot the environment before the dotenv files are exported into it, so
+	// the provenance tells them apart.
+	environment := config.Environment()
+
+	star.mode = star.configArgs.Mode
+	if star.mode == "" {
+		star.mode = config.DotEnvMode()
+	}
+	if star.mode == "" {
+		star.mode = localEnv
 	}
 
-	errDotEnv := godotenv.Load(".env")
-	if errDotEnv != nil {
-		panic(fmt.Sprintf("Error loading .env file, err: %s", err))
+	dotEnvFiles, err := config.DotEnvFiles(star.mode)
+	if err != nil {
+		return err
+	}
+	if len(dotEnvFiles) == 0 {
+		if star.mode == localEnv {
+			return errors.New("no .env or .env.local file found, local mode reads its settings from them")
+		}
+		log.Warn().Msg(fmt.Sprintf("No .env, .env.%s or .env.local file found, using the environment only", star.mode))
+	}
+	if err := config.ExportDotEnv(dotEnvFiles); err != nil {
+		return err
+	}
+	// go-flags reads config_file from the environment before the dotenv files
+	// are exported into it, so the one they set is read here.
+	if star.configArgs.ConfigFile == "" {
+		star.configArgs.ConfigFile = config.GetParamOr(config.EnvConfigFile, "")
 	}
 
-	star.mode = config.GetParamOr("mode", localEnv)
 	awsRegion := config.GetParamOr(awsRegionEnv, awsRegionDefault)
 
-	log.Info().Msg(fmt.Sprintf("Starting Service in mode ** %s ** in region ** %s ** port ** %d **\n", star.mode, awsRegion, star.args.Port))
+	log.Info().Msg(fmt.Sprintf("Starting Service in mode ** %s ** in region ** %s **\n", star.mode, awsRegion))
+
+	// Secrets are read outside local mode, from <mode>/template unless secret_names
+	// lists others, and in local mode only when secret_names is set.
+	providerKind := config.GetParamOr(secretsProvider, config.SecretsManager)
+	var secrets []string
+	if names := config.GetParamOr(secretNames, ""); names != "" {
+		secrets = strings.Split(names, ",")
+	} else if star.mode != localEnv {
+		secrets = []string{fmt.Sprintf("%s/template", star.mode)}
+	}
 
+	// The AWS config is loaded outside local mode, and in local mode when the
+	// secret provider first reads a secret, such as one a secret:// reference
+	// names without secret_names listing it.
+	var awsLoaded sync.Once
+	var awsErr error
+	loadAWSConfig := func(ctx context.Context) (aws.Config, error) {
+		awsLoaded.Do(func() {
+			started := time.Now()
+			awsCfg, awsErr = awsConfig.LoadDefaultConfig(ctx, awsConfig.WithRegion(awsRegion), awsConfig.WithAPIOptions(awsCfg.APIOptions))
+			if err := star.check("aws config", started, awsErr); err != nil {
+				awsErr = fmt.Errorf("error loading AWS config: %w", err)
+			}
+		})
+		return awsCfg, awsErr
+	}
 	if star.mode != localEnv {
-		awsCfg, err = awsConfig.LoadDefaultConfig(context.Background(), awsConfig.WithRegion(awsRegion))
-		if err != nil {
-			log.Info().Msg(fmt.Sprintf("error loading AWS config: %v\n", err))
-			os.Exit(1)
+		if _, err := loadAWSConfig(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	overrides := make(map[string]string, len(star.configArgs.Settings))
+	for _, setting := range star.configArgs.Settings {
+		key, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			return fmt.Errorf("--set %s: expected key=value", setting)
 		}
+		overrides[key] = value
+	}
+
+	var sources []config.Source
+	if star.configArgs.ConfigFile != "" {
+		sources = append(sources, config.FileSource(star.configArgs.ConfigFile))
+	}
+	for _, file := range dotEnvFiles {
+		sources = append(sources, config.DotEnvSource(file))
+	}
+	sources = append(sources, config.StaticSource("env", environment))
+	provider, err := config.NewSecretProvider(providerKind, loadAWSConfig)
+	if err != nil {
+		return err
+	}
+	for _, name := range secrets {
+		sources = append(sources, config.SecretSource(provider, strings.TrimSpace(name)))
+	}
+	sources = append(sources, config.StrictSource(config.StaticSource("flags", overrides)))
+
+	started := time.Now()
+	settings, provenance, err := config.Load(context.Background(), provider, sections, sources...)
+	if len(secrets) > 0 {
+		star.check("secrets", started, err)
+	}
+	// The provenance is kept when the settings are invalid, for config provenance.
+	star.provenance = provenance
+	if err != nil {
+		return err
 	}
 
-	star.settingsMap = config.GetSettings(star.mode, awsCfg)
+	star.settingsMap = settings
+	star.watcher = config.NewWatcher(settings, provider, sections, sources...)
+	zerolog.SetGlobalLevel(settings.Logging.ZerologLevel())
+	star.watcher.Subscribe("logging", func(_ context.Context, previous, current *config.Settings, _ []string) error {
+		if config.Changed(previous.Logging.Level, current.Logging.Level) {
+			zerolog.SetGlobalLevel(current.Logging.ZerologLevel())
+		}
+		return nil
+	})
+
+	// The secrets were read in the region of the environment and the dotenv
+	// files, the clients built from now on use the one of every source.
+	if settings.AWS.Region != awsRegion {
+		log.Info().Msg(fmt.Sprintf("Using region ** %s ** from %s", settings.AWS.Region, provenance.Source("aws_region")))
+	}
+	awsCfg.Region = settings.AWS.Region
 	star.awsCfg = awsCfg
+
+	return nil
 }
 
-func (star *Starship) setWebServer() {
+// setWebServer builds the router and binds the listener, so a port already in
+// use fails the build rather than the serve loop.
+func (star *Starship) setWebServer() error {
 	log.Info().Msg("Starting Web Server...")
 
-	port := fmt.Sprintf(":%d", star.args.Port)
-	webServer := apiserver.NewServer(&star.awsCfg)
+	addr := net.JoinHostPort(star.args.Address, strconv.Itoa(star.args.Port))
+	webServer, r, err := star.newRouter()
+	if err != nil {
+		return err
+	}
+	star.watcher.Subscribe("cors", func(_ context.Context, previous, current *config.Settings, _ []string) error {
+		if !config.Changed(previous.HTTP.CorsOrigins, current.HTTP.CorsOrigins) {
+			return nil
+		}
+		return webServer.SetAllowedOrigins(current.HTTP.AllowedOrigins())
+	})
+	star.watcher.Subscribe("access log", func(_ context.Context, previous, current *config.Settings, _ []string) error {
+		if !config.Changed(accessLogOptions(previous.Logging), accessLogOptions(current.Logging)) {
+			return nil
+		}
+		webServer.SetAccessLogOptions(accessLogOptions(current.Logging))
+		return nil
+	})
+
+	tlsConfig, err := star.tlsConfig()
+	if err != nil {
+		return fmt.Errorf("configuring TLS: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           r,
+		TLSConfig:         tlsConfig,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	star.lifecycle.OnShutdown("web server", func(ctx context.Context) error {
+		webServer.SetReady(false)
+		if star.args.ShutdownDelay > 0 {
+			log.Info().Msg(fmt.Sprintf("Draining, waiting %s before closing the listener", star.args.ShutdownDelay))
+			select {
+			case <-time.After(star.args.ShutdownDelay):
+			case <-ctx.Done():
+			}
+		}
+
+		return server.Shutdown(ctx)
+	})
+
+	star.webServer = webServer
+	star.server = server
+	star.listener = listener
+
+	return nil
+}
+
+// serve blocks until the server fails or a shutdown signal is received.
+func (star *Starship) serve() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if star.server.TLSConfig != nil {
+			log.Info().Msg(fmt.Sprintf("Listening on https://%s", star.server.Addr))
+			serveErr <- star.server.ServeTLS(star.listener, "", "")
+			return
+		}
+		log.Info().Msg(fmt.Sprintf("Listening on http://%s", star.server.Addr))
+		serveErr <- star.server.Serve(star.listener)
+	}()
+	star.webServer.SetReady(true)
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+	case <-ctx.Done():
+		log.Info().Msg("Shutdown signal received")
+	}
+
+	return nil
+}
+
+func (star *Starship) setWorkers() error {
+	// only tracks whether each worker requested with --only was found.
+	only := map[string]bool{}
+	for _, name := range star.workerArgs.Only {
+		only[name] = false
+	}
+	filtered := len(only) > 0
+
+	star.workers = worker.NewRunner(star.workerArgs.Concurrency)
+	count := 0
+	for _, m := range star.modules {
+		if m.Workers == nil {
+			continue
+		}
+
+		consumers, err := m.Workers(star.deps)
+		if err != nil {
+			return fmt.Errorf("module %s: %w", m.Name, err)
+		}
+		for _, c := range consumers {
+			if _, ok := only[c.Name]; filtered && !ok {
+				continue
+			}
+			only[c.Name] = true
+			star.workers.Add(c)
+			count++
+		}
+	}
+
+	for name, found := range only {
+		if !found {
+			return fmt.Errorf("no worker named %q is registered", name)
+		}
+	}
+	if count == 0 {
+		return errors.New("no workers are registered")
+	}
+
+	return nil
+}
+
+// runWorkers blocks until a shutdown signal is received or every worker is done.
+// The workers are stopped by a shutdown hook so they drain within the shutdown timeout.
+func (star *Starship) runWorkers() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		star.workers.Run(ctx)
+		close(done)
+	}()
+
+	star.lifecycle.OnShutdown("workers", func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return errors.New("workers did not stop within the shutdown timeout")
+		}
+	})
+
+	signalCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	select {
+	case <-signalCtx.Done():
+		log.Info().Msg("Shutdown signal received")
+	case <-done:
+		log.Info().Msg("All workers finished")
+	}
+
+	return nil
+}
+
+// setTracing sets up the export of the spans of the requests, the queries and
+// the AWS calls, which setConfig instruments. It runs before the database is
+// created so that it is instrumented.
+func (star *Starship) setTracing() error {
+	shutdown, err := tracing.Setup(context.Background(), star.settingsMap.Tracing, star.mode)
+	if err != nil {
+		return err
+	}
+	star.lifecycle.OnShutdown("tracing", shutdown)
+
+	if endpoint := star.settingsMap.Tracing.Endpoint; endpoint != "" {
+		log.Info().Msg(fmt.Sprintf("Exporting traces to %s", endpoint))
+	}
+	return nil
+}
+
+// setHealthChecks registers the checks of the dependencies the build set up,
+// run by the probes of the web server and the admin /health endpoint.
+func (star *Starship) setHealthChecks() error {
+	var checks []health.Check
+	if db := star.database(); db != nil {
+		cfg := star.dbConfig()
+		checks = append(checks,
+			health.Check{Name: "mysql write", Probes: []health.Probe{health.Readiness}, Run: db.Pool.PingContext},
+			health.Check{Name: "mysql read", Probes: []health.Probe{health.Readiness}, Run: db.PoolRead.PingContext},
+			health.Check{Name: "migrations", Probes: []health.Probe{health.Startup}, CacheFor: time.Minute,
+				Run: func(ctx context.Context) error {
+					pending, err := mysql.PendingMigrations(ctx, db.Pool, cfg)
+					if err != nil {
+						return err
+					}
+					if len(pending) > 0 {
+						return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+					}
+					return nil
+				}},
+		)
+	}
+
+	if bucket := star.settingsMap.AWS.S3Bucket; bucket != "" {
+		client := s3.NewFromConfig(star.awsCfg)
+		checks = append(checks, health.Check{Name: "s3", Run: func(ctx context.Context) error {
+			_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(bucket)})
+			return err
+		}})
+	}
+
+	// Secrets are only refreshed by the config watcher, they are stale once
+	// several reloads in a row failed.
+	if interval := star.args.ConfigReloadInterval; interval > 0 {
+		checks = append(checks, health.Check{Name: "secrets freshness", Run: func(_ context.Context) error {
+			if age := time.Since(star.watcher.LoadedAt()); age > 3*interval {
+				return fmt.Errorf("settings last loaded %s ago", age.Round(time.Second))
+			}
+			return nil
+		}})
+	}
+
+	for _, check := range checks {
+		if err := star.health.Register(check); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setAdminServer serves the admin endpoints on their own listener when
+// --admin-address is set. It is meant for operators and must not be exposed publicly.
+func (star *Starship) setAdminServer() error {
+	if star.args.AdminAddress == "" {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", star.args.AdminAddress)
+	if err != nil {
+		return err
+	}
+
+	admin := apiserver.NewAdminServer(star.database(), star.watcher.Current, star.health, star.metrics)
+	server := &http.Server{
+		Addr:              star.args.AdminAddress,
+		Handler:           admin.Routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		log.Info().Msg(fmt.Sprintf("Admin server listening on http://%s", star.args.AdminAddress))
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("admin server stopped")
+		}
+	}()
+
+	star.lifecycle.OnShutdown("admin server", server.Shutdown)
+
+	return nil
+}
+
+// watchConfig reloads the settings every --config-reload-interval and notifies
+// the subscribers registered by the previous steps of the changes.
+func (star *Starship) watchConfig() error {
+	if star.args.ConfigReloadInterval <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go star.watcher.Watch(ctx, star.args.ConfigReloadInterval)
+	star.lifecycle.OnShutdown("config watcher", func(_ context.Context) error {
+		cancel()
+		return nil
+	})
+
+	log.Info().Msg(fmt.Sprintf("Reloading the settings every %s", star.args.ConfigReloadInterval))
+	return nil
+}
+
+// tlsConfig returns nil when the server should serve plain HTTP.
+func (star *Starship) tlsConfig() (*tls.Config, error) {
+	if !star.args.TLS && star.args.TLSCert == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if star.args.TLSCert == "" {
+		if star.mode != localEnv {
+			return nil, errors.New("--tls-cert and --tls-key are required outside local mode")
+		}
+
+		log.Warn().Msg("Serving HTTPS with a generated self-signed certificate")
+		cert, err := apiserver.SelfSignedCertificate("localhost", "127.0.0.1", "::1", star.args.Address)
+		if err != nil {
+			return nil, fmt.Errorf("generating self-signed certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+
+		return tlsConfig, nil
+	}
+
+	if star.args.TLSKey == "" {
+		return nil, errors.New("--tls-key is required with --tls-cert")
+	}
+
+	reloader, err := apiserver.NewCertReloader(star.args.TLSCert, star.args.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.GetCertificate = reloader.GetCertificate
+
+	if star.args.TLSReloadInterval == 0 {
+		log.Info().Msg("TLS certificate reload disabled")
+		return tlsConfig, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	go reloader.Watch(ctx, star.args.TLSReloadInterval)
+	star.lifecycle.OnShutdown("certificate reloader", func(_ context.Context) error {
+		cancel()
+		return nil
+	})
+
+	return tlsConfig, nil
+}
+
+func (star *Starship) newRouter() (*apiserver.ApiServer, *chi.Mux, error) {
+	webServer := apiserver.NewServer(&star.awsCfg, star.database(), star.health, star.metrics)
+	for _, m := range star.modules {
+		if m.Routes == nil {
+			continue
+		}
+
+		m := m
+		routes := func(r chi.Router) {
+			if m.RequiresDatabase {
+				r.Use(webServer.RequireDatabase)
+			}
+			m.Routes(r, star.deps)
+		}
+		if len(m.Versions) > 0 {
+			webServer.AddVersionedRoutes(routes, m.Versions...)
+		} else {
+			webServer.AddRoutes(routes)
+		}
+	}
+
+	deprecations, err := star.settingsMap.HTTP.Deprecations()
+	if err != nil {
+		return nil, nil, err
+	}
+	for version, sunset := range deprecations {
+		webServer.DeprecateVersion(version, sunset)
+	}
+
+	webServer.SetAccessLogOptions(accessLogOptions(star.settingsMap.Logging))
 
 	r := chi.NewRouter()
-	webServer.SetupRoutes(star.mode, r, star.args.Port, star.settingsMap.CorsOrigins)
+	basePath := star.settingsMap.HTTP.APIBasePath(star.mode)
+	if err := webServer.SetupRoutes(basePath, r, star.args.Port, star.settingsMap.HTTP.AllowedOrigins()); err != nil {
+		return nil, nil, err
+	}
+
+	return webServer, r, nil
+}
+
+func accessLogOptions(settings config.LoggingSettings) apiserver.AccessLogOptions {
+	return apiserver.AccessLogOptions{
+		HealthSampleEvery: settings.HealthSampleEvery,
+		SlowRequest:       settings.SlowRequest,
+	}
+}
+
+func (star *Starship) setRouteDocs(opts RouteDocsOptions) error {
+	_, r, err := star.newRouter()
+	if err != nil {
+		return err
+	}
+
+	if opts == (RouteDocsOptions{}) {
+		fmt.Println(docgen.JSONRoutesDoc(r))
+		return nil
+	}
+
+	if opts.JSON != "" {
+		if err := writeFile(opts.JSON, []byte(docgen.JSONRoutesDoc(r))); err != nil {
+			return err
+		}
+	}
+
+	if opts.Markdown != "" {
+		markdown := docgen.MarkdownRoutesDoc(r, docgen.MarkdownOpts{
+			ProjectPath: "template",
+			Intro:       fmt.Sprintf("Routes of the Starship Enterprise API in mode %s.", star.mode),
+		})
+		if err := writeFile(opts.Markdown, []byte(markdown)); err != nil {
+			return err
+		}
+	}
+
+	if opts.Snapshot == "" && opts.Check == "" {
+		return nil
+	}
+
+	snapshot, err := apiserver.SnapshotRoutes(r)
+	if err != nil {
+		return err
+	}
+
+	if opts.Snapshot != "" {
+		out, err := json.MarshalIndent(snapshot, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFile(opts.Snapshot, append(out, '\n')); err != nil {
+			return err
+		}
+	}
+
+	if opts.Check != "" {
+		return checkRoutes(opts.Check, snapshot)
+	}
+
+	return nil
+}
+
+// checkRoutes fails when routes of the committed snapshot were removed or changed.
+func checkRoutes(path string, current apiserver.RouteSnapshot) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading route snapshot: %w", err)
+	}
+
+	var committed apiserver.RouteSnapshot
+	if err := json.Unmarshal(content, &committed); err != nil {
+		return fmt.Errorf("parsing route snapshot %s: %w", path, err)
+	}
+
+	diff := apiserver.DiffRoutes(committed, current)
+	if diff.Breaking() {
+		return fmt.Errorf("routes changed in a breaking way compared to %s:\n%s", path, diff)
+	}
 
-	if star.args.Routes {
-		log.Info().Msg(docgen.JSONRoutesDoc(r))
+	if len(diff.Added) > 0 {
+		log.Info().Msg(fmt.Sprintf("Routes added since %s, update the snapshot with --snapshot:\n%s", path, diff))
+	} else {
+		log.Info().Msg(fmt.Sprintf("Routes match %s", path))
+	}
+
+	return nil
+}
+
+func writeFile(path string, content []byte) error {
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return err
+	}
 
+	log.Info().Msg(fmt.Sprintf("Wrote %s", path))
+	return nil
+}
+
+// shutdown runs the lifecycle hooks bounded by the configured drain timeout.
+func (star *Starship) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), star.args.ShutdownTimeout)
+	defer cancel()
+
+	if err := star.lifecycle.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("shutdown finished with errors")
 		return
 	}
 
-	if err := http.ListenAndServe(port, r); err != nil {
-		panic(err)
+	log.Info().Msg("Shutdown complete")
+}
+
+func (star *Starship) databaseEnabled() bool {
+	return star.args.Database != databaseNone
+}
+
+func (star *Starship) setDatabase() error {
+	ctx := context.Background()
+	cfg := star.dbConfig()
+
+	started := time.Now()
+	db, err := mysql.NewDB(ctx, cfg, star.awsCfg)
+	if err := star.check("mysql", started, err); err != nil {
+		return fmt.Errorf("failed to setup db: %w", err)
+	}
+
+	star.Database = db
+	star.lifecycle.OnShutdown("database", func(ctx context.Context) error {
+		return star.Database.Close()
+	})
+	if err := star.metrics.MonitorDB("write", db.Pool); err != nil {
+		return err
+	}
+	if err := star.metrics.MonitorDB("read", db.PoolRead); err != nil {
+		return err
+	}
+	star.watcher.Subscribe("database", func(ctx context.Context, previous, current *config.Settings, _ []string) error {
+		if !config.Changed(previous.Database, current.Database) {
+			return nil
+		}
+		cfg := star.dbConfig()
+		cfg.Database = current.Database
+		return star.Database.Reconfigure(ctx, cfg, star.awsCfg)
+	})
+
+	if !cfg.SkipMigrations {
+		log.Info().Msg("Migrating database...")
+		started := time.Now()
+		err := mysql.Migrate(ctx, cfg, star.awsCfg)
+		if err := star.check("migrations", started, err); err != nil {
+			return fmt.Errorf("failed to migrate db: %w", err)
+		}
+	}
+
+	log.Info().Msg("DB setup successfully")
+
+	return nil
+}
+
+func (star *Starship) setRepositories() error {
+	if star.database() == nil {
+		log.Warn().Msg("Running without a database, repositories are in-memory and data is lost on restart")
+		star.Repositories = memory.NewRepositories()
+	} else {
+		star.Repositories = repositories.NewRepositories(star.Database)
+	}
+
+	star.deps = modules.NewDeps(star.mode, star.settingsMap, star.awsCfg, star.database())
+	star.deps.Health = star.health
+	star.deps.Metrics = star.metrics
+	star.deps.Provide(coreRepositories, star.Repositories)
+
+	for _, m := range star.modules {
+		if m.Repositories == nil {
+			continue
+		}
+		if err := m.Repositories(star.deps); err != nil {
+			return fmt.Errorf("module %s: %w", m.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (star *Starship) setServices() error {
+	for _, m := range star.modules {
+		if m.Services == nil {
+			continue
+		}
+		if err := m.Services(star.deps); err != nil {
+			return fmt.Errorf("module %s: %w", m.Name, err)
+		}
 	}
+
+	return nil
 }
 
-func (star *Starship) setDatabase() {
-	star.Database = mysql.MustSetupDB(context.Background(), star.awsCfg, mysql.DBConfig{Settings: *star.settingsMap})
+func (star *Starship) setModules(ordered []modules.Module) {
+	star.modules = ordered
+	for _, m := range ordered {
+		log.Info().Msg(fmt.Sprintf("Module enabled: %s", m.Name))
+	}
 }
 
-func (star *Starship) setRepositories() {
+// startModules runs the Start hooks in dependency order and registers the Stop
+// hooks so that they run in reverse order on shutdown.
+func (star *Starship) startModules() error {
+	for _, m := range star.modules {
+		if m.Start != nil {
+			if err := m.Start(context.Background()); err != nil {
+				return fmt.Errorf("module %s: %w", m.Name, err)
+			}
+		}
+		if m.Stop != nil {
+			star.lifecycle.OnShutdown("module "+m.Name, m.Stop)
+		}
+	}
+
+	return nil
 }
 
-func (star *Starship) setServices() {
+// database returns nil when no database is connected.
+func (star *Starship) database() *mysql.DB {
+	if star.Database.Pool == nil {
+		return nil
+	}
+	return &star.Database
+}
+
+func (star *Starship) dbConfig() mysql.DBConfig {
+	var migrations []mysql.MigrationSource
+	for _, m := range star.modules {
+		if m.Migrations != nil {
+			migrations = append(migrations, mysql.MigrationSource{Name: m.Name, FS: m.Migrations})
+		}
+	}
+
+	return mysql.DBConfig{
+		SkipMigrations: star.args.SkipMigrations,
+		Database:       star.settingsMap.Database,
+		Migrations:     migrations,
+	}
+}
+
+func (star *Starship) runMigrations(source, command string, args []string) error {
+	if command == "create" {
+		return mysql.CreateMigration(args[0])
+	}
+
+	log.Info().Msg(fmt.Sprintf("Running migration command: %s", command))
+	return mysql.RunMigrations(context.Background(), star.dbConfig(), star.awsCfg, source, command, args...)
+}
+
+func (star *Starship) runSeeder(dataStore mysql.SeedDataStore) error {
+	log.Info().Msg("Seeding database...")
+	return mysql.Seeder(context.Background(), star.dbConfig(), star.awsCfg, dataStore)
+}
+
+func (star *Starship) inspectConfig(command string) error {
+	switch command {
+	case "print":
+		out, err := json.MarshalIndent(star.settingsMap.Redacted(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	case "provenance":
+		out, err := json.MarshalIndent(star.provenance, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	case "validate":
+		log.Info().Msg(fmt.Sprintf("Configuration for mode ** %s ** is valid", star.mode))
+	default:
+		return fmt.Errorf("%q: no such config command", command)
+	}
+
+	return nil
 }
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"template/config"
+)
+
+// fakeBuilder records the config commands the director inspects, the other
+// steps are not expected to run.
+type fakeBuilder struct {
+	StarshipBuilder
+	configErr error
+	inspected []string
+}
+
+func (b *fakeBuilder) setConfig(_ []string) error          { return b.configErr }
+func (b *fakeBuilder) dependencyChecks() []DependencyCheck { return nil }
+func (b *fakeBuilder) inspectConfig(command string) error {
+	b.inspected = append(b.inspected, command)
+	return nil
+}
+
+func TestBuildConfigInspector(t *testing.T) {
+	invalid := &config.ValidationError{Problems: []*config.FieldError{{Key: "db_port", Source: "flags", Err: config.ErrOutOfRange}}}
+
+	tests := []struct {
+		name          string
+		command       string
+		configErr     error
+		wantInspected []string
+		wantCode      int
+	}{
+		{name: "provenance", command: "provenance", wantInspected: []string{"provenance"}},
+		{name: "provenance of invalid settings", command: "provenance", configErr: invalid, wantInspected: []string{"provenance"}, wantCode: ExitConfig},
+		{name: "validate invalid settings", command: "validate", configErr: invalid, wantCode: ExitConfig},
+		{name: "provenance without settings", command: "provenance", configErr: errors.New("no .env file"), wantCode: ExitConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := &fakeBuilder{configErr: tt.configErr}
+			director := NewStarshipBuilder(builder)
+
+			err := director.BuildConfigInspector(tt.command)
+
+			if !reflect.DeepEqual(builder.inspected, tt.wantInspected) {
+				t.Errorf("inspected %v, want %v", builder.inspected, tt.wantInspected)
+			}
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("BuildConfigInspector: %v", err)
+				}
+				return
+			}
+			if got := exitCode(err); got != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", got, tt.wantCode)
+			}
+			if tt.configErr == invalid {
+				if got := director.report.Steps[0].Error; got != "invalid settings: db_port" {
+					t.Errorf("startup report error = %q", got)
+				}
+			}
+		})
+	}
+}
+
+// TestSetConfigSecretReference resolves a secret:// reference in local mode
+// without secret_names, against a fake Secrets Manager.
+func TestSetConfigSecretReference(t *testing.T) {
+	secretsManager := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if target := r.Header.Get("X-Amz-Target"); target != "secretsmanager.GetSecretValue" {
+			http.Error(w, target, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"Name": "dev/template", "SecretString": "{\"db_password\": \"from-secrets-manager\"}"}`))
+	}))
+	defer secretsManager.Close()
+
+	for key, value := range map[string]string{
+		"AWS_ENDPOINT_URL_SECRETS_MANAGER": secretsManager.URL,
+		"AWS_ACCESS_KEY_ID":                "test",
+		"AWS_SECRET_ACCESS_KEY":            "test",
+		"AWS_CONFIG_FILE":                  os.DevNull,
+		"AWS_SHARED_CREDENTIALS_FILE":      os.DevNull,
+		"AWS_EC2_METADATA_DISABLED":        "true",
+		"http_cors_origins":                "https://app.example.com",
+		"db_user":                          "app",
+		"db_database":                      "app",
+		"db_password":                      "secret://dev/template#db_password",
+		"log_level":                        "info",
+	} {
+		t.Setenv(key, value)
+	}
+	for _, key := range []string{"mode", "aws_region", secretNames, secretsProvider, "AWS_REGION", "AWS_PROFILE"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	// Local mode needs a dotenv file, the environment sets the same value.
+	if err := os.WriteFile(".env", []byte("log_level=info\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	star := NewStarship()
+	if err := star.setConfig([]string{"http", "db"}); err != nil {
+		t.Fatalf("setConfig: %v", err)
+	}
+	if got := star.settingsMap.Database.Password; got != "from-secrets-manager" {
+		t.Errorf("db_password = %q", got)
+	}
+	if ended := spans.Ended(); len(ended) != 1 || ended[0].Name() != "Secrets Manager.GetSecretValue" {
+		t.Errorf("spans = %v, want the GetSecretValue call", ended)
+	}
+	if star.awsCfg.Region != awsRegionDefault {
+		t.Errorf("region = %q, want %q", star.awsCfg.Region, awsRegionDefault)
+	}
+}
@@ -2,34 +2,149 @@ package config
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"reflect"
+	"slices"
 	"strings"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/rs/zerolog/log"
+	"github.com/rs/zerolog"
 )
 
+// Settings is the configuration of the service, one section per concern. The
+// json name of a section prefixes the names of its settings: the Database
+// section is named db, so its Host is set with db_host and, in a config file,
+// with host nested under db.
 type Settings struct {
-	CorsOrigins     string        `json:"cors_origins"`
-	Host            string        `json:"db_host"`
-	HostRead        string        `json:"db_host_read"`
-	Port            string        `json:"db_port" default:"3306"`
-	Username        string        `json:"db_user" required:"true"`
-	Password        string        `json:"db_password"`
-	Database        string        `json:"db_database" required:"true"`
-	ConnectTimeout  time.Duration `json:"db_connect_timeout" default:"2s"`
-	MaxConnLifetime time.Duration `json:"db_max_conn_life_time" default:"1h"`
-	MaxConns        int           `json:"db_max_conns" default:"5"`
-	MinConns        int           `json:"db_min_conns" default:"5"`
-	RefreshPassword bool          `json:"db_refresh_password" default:"false"`
-	TZ              *string       `json:"db_time_zone"`
-	DBRestore       bool          `json:"db_restore" default:"false"`
-	DeviceKeySecret string        `json:"device_key"`
+	HTTP     HTTPSettings     `json:"http"`
+	Database DatabaseSettings `json:"db"`
+	AWS      AWSSettings      `json:"aws"`
+	Security SecuritySettings `json:"security"`
+	Logging  LoggingSettings  `json:"log"`
+	Tracing  TracingSettings  `json:"tracing"`
+}
+
+// Names of the sections, for the commands to list the ones they use.
+const (
+	SectionHTTP     = "http"
+	SectionDatabase = "db"
+	SectionAWS      = "aws"
+	SectionSecurity = "security"
+	SectionLogging  = "log"
+	SectionTracing  = "tracing"
+)
+
+type HTTPSettings struct {
+	CorsOrigins        string `json:"cors_origins" alias:"cors_origins" required:"true" description:"Comma separated origins allowed by CORS, each may hold one wildcard"`
+	BasePath           string `json:"base_path" description:"URL prefix of the API, e.g. /api, / for none. Defaults to /<mode> when empty"`
+	DeprecatedVersions string `json:"deprecated_versions" description:"Comma separated deprecated API versions, each optionally followed by @<sunset date>, e.g. v1@2027-01-31"`
+}
+
+// AllowedOrigins returns the CORS origins as a list.
+func (h HTTPSettings) AllowedOrigins() []string {
+	origins := strings.Split(h.CorsOrigins, ",")
+	for i, origin := range origins {
+		origins[i] = strings.TrimSpace(origin)
+	}
+	return origins
+}
+
+// ValidateAllowedOrigins rejects the CORS origins the web server refuses.
+func ValidateAllowedOrigins(origins []string) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			return errors.New("wildcard '*' is not allowed in CORS allowed origins")
+		}
+	}
+	return nil
+}
+
+type DatabaseSettings struct {
+	Host            string        `json:"host" description:"MySQL host of the write pool"`
+	HostRead        string        `json:"host_read" description:"MySQL host of the read pool"`
+	Port            string        `json:"port" default:"3306" description:"MySQL port"`
+	User            string        `json:"user" required:"true" description:"MySQL user"`
+	Password        string        `json:"password" secret:"true" description:"MySQL password, an IAM authentication token is used when empty"`
+	Name            string        `json:"database" required:"true" description:"MySQL database"`
+	ConnectTimeout  time.Duration `json:"connect_timeout" default:"15s" description:"Timeout of new connections"`
+	MaxConnLifetime time.Duration `json:"max_conn_life_time" default:"1h" description:"Maximum time a connection is reused"`
+	MaxConns        int           `json:"max_conns" default:"5" description:"Maximum open connections of each pool"`
+	MinConns        int           `json:"min_conns" default:"5" description:"Idle connections kept by each pool"`
+	RefreshPassword bool          `json:"refresh_password" default:"false" description:"Refresh the IAM authentication token"`
+	TimeZone        *string       `json:"time_zone" description:"Time zone of the connections"`
+	Restore         bool          `json:"restore" default:"false" description:"Restore the database"`
+}
+
+// APIBasePath returns the URL prefix of the API, /<mode> unless set.
+func (h HTTPSettings) APIBasePath(mode string) string {
+	if h.BasePath == "" {
+		return "/" + mode
+	}
+	return h.BasePath
+}
+
+// Deprecations returns the sunset date of each deprecated API version, zero
+// when it has none.
+func (h HTTPSettings) Deprecations() (map[string]time.Time, error) {
+	deprecations := make(map[string]time.Time)
+	for _, entry := range strings.Split(h.DeprecatedVersions, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		version, date, dated := strings.Cut(entry, "@")
+		var sunset time.Time
+		if dated {
+			var err error
+			sunset, err = time.Parse(time.DateOnly, date)
+			if err != nil {
+				return nil, fmt.Errorf("%s: sunset date %q is not a YYYY-MM-DD date", version, date)
+			}
+		}
+		deprecations[version] = sunset
+	}
+	return deprecations, nil
+}
+
+// Address returns the host and port of the write pool.
+func (d DatabaseSettings) Address() string {
+	return net.JoinHostPort(d.Host, d.Port)
+}
+
+type AWSSettings struct {
+	// Region is read from the environment and the dotenv files before the other
+	// sources to reach the secrets, the AWS clients then use its value from every source.
+	Region   string `json:"region" default:"us-west-2" description:"AWS region"`
+	S3Bucket string `json:"s3_bucket" description:"S3 bucket whose reachability the health check reports, not checked when empty"`
+}
+
+type SecuritySettings struct {
+	DeviceKey string `json:"device_key" alias:"device_key" secret:"true" description:"Key used to authenticate devices"`
+}
+
+type LoggingSettings struct {
+	Level             string        `json:"level" default:"info" description:"Log level: trace, debug, info, warn, error, fatal or panic"`
+	HealthSampleEvery uint32        `json:"health_sample_every" default:"10" description:"Log one in N successful requests to the health probes, 0 to log none of them"`
+	SlowRequest       time.Duration `json:"slow_request" default:"1s" description:"Requests slower than this are logged as warnings with slow set, 0 to disable"`
+}
+
+// ZerologLevel returns the level as a zerolog level, info when invalid.
+func (l LoggingSettings) ZerologLevel() zerolog.Level {
+	level, err := zerolog.ParseLevel(l.Level)
+	if err != nil {
+		return zerolog.InfoLevel
+	}
+	return level
+}
+
+type TracingSettings struct {
+	Endpoint    string  `json:"endpoint" description:"OTLP/HTTP endpoint of the trace collector, e.g. localhost:4318, spans are not exported when empty"`
+	Insecure    bool    `json:"insecure" default:"false" description:"Export the spans over plain HTTP, e.g. to a local collector"`
+	SampleRatio float64 `json:"sample_ratio" default:"1" description:"Share of the new traces that are sampled, between 0 and 1, incoming sampled traces are always followed"`
+	ServiceName string  `json:"service_name" default:"template" description:"Service name reported with the spans"`
 }
 
 func GetParamOr(param, orElse string) string {
@@ -40,100 +155,171 @@ func GetParamOr(param, orElse string) string {
 	return p
 }
 
-func GetSettings(mode string, awsCfg aws.Config) *Settings {
-	parseJsonSettings := func(secret string) *Settings {
-		var config Settings
-		err := json.Unmarshal([]byte(secret), &config)
-		if err != nil {
-			panic(err)
-		}
+// Source names of the provenance report for settings no source supplied.
+const (
+	SourceDefault = "default"
+	SourceUnset   = "unset"
+)
 
-		// verify that all required secrets are present
-		requiredSettings := []string{"Database", "CorsOrigins"}
+// Origin is the source that supplied a setting.
+type Origin struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+}
 
-		v := reflect.ValueOf(config)
-		for _, field := range requiredSettings {
-			f := v.FieldByName(field)
-			if !f.IsValid() {
-				panic(fmt.Sprintf("Required setting %s is missing", field))
-			}
+// Provenance lists the origin of every setting, in the order of the Settings fields.
+type Provenance []Origin
+
+// Source returns the source of a setting, empty for an unknown key.
+func (p Provenance) Source(key string) string {
+	for _, origin := range p {
+		if origin.Key == key {
+			return origin.Source
 		}
+	}
+	return ""
+}
 
-		return &config
+// Load reads the sources in order and builds the settings. Every setting starts
+// from its default tag and each source overrides the ones before it, so sources
+// are passed lowest precedence first. Values written secret://<name>#<key> are
+// then replaced by the key of the secret, read from the secrets provider.
+// Only the required settings of the given sections, those the command uses,
+// must be set, and strict sources must only hold settings. The provenance is returned even when the settings are invalid,
+// and each problem names the source of its value.
+func Load(ctx context.Context, secrets SecretProvider, sections []string, sources ...Source) (*Settings, Provenance, error) {
+	for _, section := range sections {
+		if !slices.Contains(Sections(), section) {
+			return nil, nil, fmt.Errorf("unknown settings section %q", section)
+		}
 	}
 
-	var jsonSettings string
-	if mode == "local" {
-		// Get secrets from local file
-		content, err := parsingEnvToSettingsJsonObj()
+	values := make(map[string]string)
+	origins := make(map[string]string)
+	problems := &ValidationError{}
+	for _, source := range sources {
+		sourceValues, err := source.Values(ctx)
 		if err != nil {
-			panic(err)
+			return nil, nil, err
 		}
-		jsonSettings = content
-	} else {
-		// Create a Secrets Manager client
-		secretsClient := secretsmanager.NewFromConfig(awsCfg)
-
-		// Build the request input
-		secretName := fmt.Sprintf("%s/template", mode)
-		secretInput := &secretsmanager.GetSecretValueInput{
-			SecretId: aws.String(secretName),
+		if _, ok := source.(strict); ok {
+			for _, key := range unknownKeys(sourceValues) {
+				problems.Problems = append(problems.Problems, &FieldError{
+					Key:    key,
+					Source: source.Name(),
+					Err:    fmt.Errorf("%w: not a setting", ErrInvalidValue),
+				})
+			}
 		}
+		for key, value := range renameAliases(source.Name(), sourceValues) {
+			values[key] = value
+			origins[key] = source.Name()
+		}
+	}
 
-		// Get secret value
-		log.Info().Msg(fmt.Sprintf("Trying to get secret: %s\n", secretName))
-		sv, err := secretsClient.GetSecretValue(context.Background(), secretInput)
-		if err != nil {
-			log.Error().Msg(fmt.Sprintf("error getting secret: %v", err))
-			panic(err)
+	if err := resolveSecretReferences(ctx, secrets, values, origins); err != nil {
+		var unresolved *ValidationError
+		if errors.As(err, &unresolved) {
+			problems.Problems = append(problems.Problems, unresolved.Problems...)
+			err = problems
 		}
+		return nil, provenanceOf(origins), err
+	}
 
-		if sv.SecretString != nil {
-			log.Info().Msg(fmt.Sprintf("Got secret: %s\n", secretName))
-			jsonSettings = ReplaceSecretValues(*sv.SecretString)
-		} else {
-			log.Info().Msg(fmt.Sprintf("could not retrieve secret: %v\n", secretName))
+	provenance := provenanceOf(origins)
+	settings, err := load(values, sections)
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		for _, p := range invalid.Problems {
+			p.Source = origins[p.Key]
 		}
+		problems.Problems = append(problems.Problems, invalid.Problems...)
+	case err != nil:
+		return nil, provenance, err
+	}
+	if err := problems.orNil(); err != nil {
+		return nil, provenance, err
 	}
 
-	return parseJsonSettings(jsonSettings)
+	return settings, provenance, nil
 }
 
-func parsingEnvToSettingsJsonObj() (string, error) {
-	envData := make(map[string]string)
-	for _, k := range os.Environ() {
-		parts := strings.SplitN(k, "=", 2)
-		if len(parts) == 2 {
-			envData[parts[0]] = parts[1]
+// load builds the settings from raw values: defaults first, then the values
+// decoded to the type of each field, then validation.
+func load(values map[string]string, sections []string) (*Settings, error) {
+	var settings Settings
+	if err := applyDefaults(&settings); err != nil {
+		return nil, err
+	}
+	// Report the values that can't be decoded along with the other problems,
+	// skipping the checks of the settings they left at their default.
+	problems := &ValidationError{}
+	failed := make(map[string]bool)
+	if err := Decode(values, &settings); err != nil {
+		if !errors.As(err, &problems) {
+			return nil, err
+		}
+		for _, p := range problems.Problems {
+			failed[p.Key] = true
+		}
+	}
+	var invalid *ValidationError
+	if errors.As(settings.Validate(sections...), &invalid) {
+		for _, p := range invalid.Problems {
+			if !failed[p.Key] {
+				problems.Problems = append(problems.Problems, p)
+			}
 		}
 	}
+	if err := problems.orNil(); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
 
-	jsonData, err := json.Marshal(envData)
-	if err != nil {
-		return "", err
+func provenanceOf(origins map[string]string) Provenance {
+	provenance := make(Provenance, 0, len(settingsFields()))
+	for _, f := range settingsFields() {
+		source, ok := origins[f.Key]
+		switch {
+		case ok:
+		case f.Tag.Get("default") != "":
+			source = SourceDefault
+		default:
+			source = SourceUnset
+		}
+		provenance = append(provenance, Origin{Key: f.Key, Source: source})
 	}
+	return provenance
+}
 
-	return string(jsonData), nil
+// Environment returns the process environment as raw values.
+func Environment() map[string]string {
+	values := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			values[key] = value
+		}
+	}
+	return values
 }
 
-func ReplaceSecretValues(secretSettings string) string {
-	content, err := parsingEnvToSettingsJsonObj()
-	if err != nil {
-		panic(err)
-	}
-	var envData, secretData map[string]interface{}
-	errEnv := json.Unmarshal([]byte(content), &envData)
-	errSec := json.Unmarshal([]byte(secretSettings), &secretData)
-	if errEnv != nil || errSec != nil {
-		panic(fmt.Sprintf("Error while parsing environment variables file: %s, %s", errEnv, errSec))
-	} else {
-		envData["db_user"] = secretData["db_user"]
-		envData["db_password"] = secretData["db_password"]
-		envData["device_key"] = secretData["device_key"]
-		jsonData, err := json.Marshal(envData)
-		if err != nil {
-			panic(err)
+const redactedValue = "********"
+
+// Redacted returns a copy of the settings with the fields tagged secret masked,
+// safe to print or log.
+func (s Settings) Redacted() Settings {
+	for _, f := range settingsFields() {
+		value := f.value(&s)
+		if f.Tag.Get("secret") != "true" || value.IsZero() {
+			continue
+		}
+		if value.Kind() == reflect.String {
+			value.SetString(redactedValue)
+		} else {
+			value.SetZero()
 		}
-		return string(jsonData)
 	}
+	return s
 }
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// requiredValues sets the required settings of every section.
+func requiredValues() map[string]string {
+	return map[string]string{
+		"http_cors_origins": "https://app.example.com",
+		"db_user":           "app",
+		"db_database":       "app",
+	}
+}
+
+func withValues(values map[string]string, extra map[string]string) map[string]string {
+	for key, value := range extra {
+		values[key] = value
+	}
+	return values
+}
+
+// writeSecret writes a secret read by the file secret provider and returns its name.
+func writeSecret(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "secret.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	secret := writeSecret(t, "db_password: from-secret\ndevice_key: key\n")
+
+	tests := []struct {
+		name     string
+		sections []string
+		sources  []Source
+		check    func(t *testing.T, s *Settings, p Provenance)
+		wantErr  []string
+		// wantSources are the sources of some of the problems.
+		wantSources map[string]string
+	}{
+		{
+			name:    "defaults",
+			sources: []Source{StaticSource("env", requiredValues())},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.Database.Port != "3306" || s.Database.MaxConns != 5 || s.Database.ConnectTimeout != 15*time.Second {
+					t.Errorf("defaults not applied: %+v", s.Database)
+				}
+				if s.Logging.Level != "info" || s.Tracing.SampleRatio != 1 {
+					t.Errorf("defaults not applied: %+v %+v", s.Logging, s.Tracing)
+				}
+				if got := p.Source("db_port"); got != SourceDefault {
+					t.Errorf("db_port source = %q, want %q", got, SourceDefault)
+				}
+				if got := p.Source("db_host"); got != SourceUnset {
+					t.Errorf("db_host source = %q, want %q", got, SourceUnset)
+				}
+			},
+		},
+		{
+			name: "later sources override earlier ones",
+			sources: []Source{
+				StaticSource("file", withValues(requiredValues(), map[string]string{"db_host": "file-host", "db_port": "3307"})),
+				StaticSource("flags", map[string]string{"db_host": "flag-host"}),
+			},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.Database.Host != "flag-host" || s.Database.Port != "3307" {
+					t.Errorf("got host %q port %q", s.Database.Host, s.Database.Port)
+				}
+				if got := p.Source("db_host"); got != "flags" {
+					t.Errorf("db_host source = %q, want flags", got)
+				}
+				if got := p.Source("db_port"); got != "file" {
+					t.Errorf("db_port source = %q, want file", got)
+				}
+			},
+		},
+		{
+			name: "aliases",
+			sources: []Source{StaticSource("env", map[string]string{
+				"cors_origins": "https://old.example.com",
+				"db_user":      "app",
+				"db_database":  "app",
+			})},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.HTTP.CorsOrigins != "https://old.example.com" {
+					t.Errorf("http_cors_origins = %q", s.HTTP.CorsOrigins)
+				}
+			},
+		},
+		{
+			name: "current key wins over its alias",
+			sources: []Source{StaticSource("env", withValues(requiredValues(), map[string]string{
+				"cors_origins": "https://old.example.com",
+			}))},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.HTTP.CorsOrigins != "https://app.example.com" {
+					t.Errorf("http_cors_origins = %q", s.HTTP.CorsOrigins)
+				}
+			},
+		},
+		{
+			name: "secret source",
+			sources: []Source{
+				StaticSource("env", withValues(requiredValues(), map[string]string{"db_password": "from-env"})),
+				SecretSource(NewFileSecretProvider(), secret),
+			},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.Database.Password != "from-secret" || s.Security.DeviceKey != "key" {
+					t.Errorf("got password %q device key %q", s.Database.Password, s.Security.DeviceKey)
+				}
+				if got := p.Source("db_password"); got != "file "+secret {
+					t.Errorf("db_password source = %q", got)
+				}
+			},
+		},
+		{
+			name: "secret reference",
+			sources: []Source{StaticSource("env", withValues(requiredValues(), map[string]string{
+				"db_password": "secret://" + secret + "#db_password",
+			}))},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.Database.Password != "from-secret" {
+					t.Errorf("db_password = %q", s.Database.Password)
+				}
+				if got := p.Source("db_password"); !strings.HasPrefix(got, "env (secret://") || !strings.HasSuffix(got, "via file)") {
+					t.Errorf("db_password source = %q", got)
+				}
+			},
+		},
+		{
+			name: "unresolved secret references",
+			sources: []Source{StaticSource("env", withValues(requiredValues(), map[string]string{
+				"db_password":         "secret://" + secret + "#missing",
+				"security_device_key": "secret://no-key",
+			}))},
+			wantErr:     []string{"db_password", "security_device_key"},
+			wantSources: map[string]string{"db_password": "env", "security_device_key": "env"},
+		},
+		{
+			name:    "required settings of every section",
+			sources: []Source{StaticSource("env", map[string]string{})},
+			wantErr: []string{"http_cors_origins", "db_user", "db_database"},
+		},
+		{
+			name: "every problem at once",
+			sources: []Source{StaticSource("env", map[string]string{
+				"http_cors_origins": "https://app.example.com, *",
+				"db_port":           "port",
+				"db_max_conns":      "0",
+				"log_level":         "loud",
+			})},
+			wantErr:     []string{"db_user", "db_database", "http_cors_origins", "db_port", "db_max_conns", "db_min_conns", "log_level"},
+			wantSources: map[string]string{"http_cors_origins": "env", "db_port": "env"},
+		},
+		{
+			name: "unknown keys of strict sources",
+			sources: []Source{
+				StaticSource("env", withValues(requiredValues(), map[string]string{"home": "/root"})),
+				StrictSource(StaticSource("flags", map[string]string{
+					"db_max_con":   "10",
+					"cors_origins": "https://old.example.com",
+					"db_port":      "port",
+				})),
+			},
+			wantErr:     []string{"db_max_con", "db_port"},
+			wantSources: map[string]string{"db_max_con": "flags", "db_port": "flags"},
+		},
+		{
+			name:     "only the required settings of the sections",
+			sections: []string{SectionHTTP},
+			sources:  []Source{StaticSource("env", map[string]string{"http_cors_origins": "https://app.example.com"})},
+			check: func(t *testing.T, s *Settings, p Provenance) {
+				if s.Database.User != "" {
+					t.Errorf("db_user = %q", s.Database.User)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sections := tt.sections
+			if sections == nil {
+				sections = Sections()
+			}
+			settings, provenance, err := Load(context.Background(), NewFileSecretProvider(), sections, tt.sources...)
+
+			if tt.wantErr != nil {
+				var problems *ValidationError
+				if !errors.As(err, &problems) {
+					t.Fatalf("err = %v, want a *ValidationError", err)
+				}
+				var keys []string
+				for _, p := range problems.Problems {
+					keys = append(keys, p.Key)
+					if want, ok := tt.wantSources[p.Key]; ok && p.Source != want {
+						t.Errorf("%s source = %q, want %q", p.Key, p.Source, want)
+					}
+				}
+				if strings.Join(keys, ",") != strings.Join(tt.wantErr, ",") {
+					t.Errorf("problems = %v, want %v", keys, tt.wantErr)
+				}
+				if len(provenance) == 0 {
+					t.Error("no provenance with invalid settings")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, settings, provenance)
+		})
+	}
+}
+
+func TestLoadUnknownSection(t *testing.T) {
+	_, _, err := Load(context.Background(), nil, []string{"database"}, StaticSource("env", requiredValues()))
+	if err == nil || !strings.Contains(err.Error(), `unknown settings section "database"`) {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadFileSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	content := `
+http:
+  cors_origins: [https://a.example.com, https://b.example.com]
+db:
+  user: app
+  database: app
+  port: 3307
+  connect_timeout: 0.5s
+log:
+  slow_request: 0
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	settings, provenance, err := Load(context.Background(), nil, Sections(), FileSource(path))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := settings.HTTP.AllowedOrigins(); strings.Join(got, " ") != "https://a.example.com https://b.example.com" {
+		t.Errorf("origins = %v", got)
+	}
+	if settings.Database.Port != "3307" || settings.Database.ConnectTimeout != 500*time.Millisecond {
+		t.Errorf("db = %+v", settings.Database)
+	}
+	if settings.Logging.SlowRequest != 0 {
+		t.Errorf("slow_request = %s", settings.Logging.SlowRequest)
+	}
+	if got := provenance.Source("db_port"); got != "file "+path {
+		t.Errorf("db_port source = %q", got)
+	}
+}
+
+func TestLoadFileSourceUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	content := `{"http": {"cors_origins": "https://app.example.com"}, "db": {"user": "app", "database": "app", "max_con": 10}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := Load(context.Background(), nil, Sections(), FileSource(path))
+
+	var problems *ValidationError
+	if !errors.As(err, &problems) || len(problems.Problems) != 1 {
+		t.Fatalf("err = %v, want one problem", err)
+	}
+	if p := problems.Problems[0]; p.Key != "db_max_con" || p.Source != "file "+path || !errors.Is(p, ErrInvalidValue) {
+		t.Errorf("problem = %v", p)
+	}
+}
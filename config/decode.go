@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyDefaults sets every field with a default tag to its default value.
+func applyDefaults(s *Settings) error {
+	problems := &ValidationError{}
+
+	for _, f := range settingsFields() {
+		def, ok := f.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		if err := setField(f.value(s), def); err != nil {
+			problems.add(f.Key, fmt.Errorf("default: %w", err))
+		}
+	}
+
+	return problems.orNil()
+}
+
+// setField converts raw to the type of the field and sets it.
+func setField(field reflect.Value, raw string) error {
+	if field.Kind() == reflect.Pointer {
+		value := reflect.New(field.Type().Elem())
+		if err := setField(value.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(value)
+		return nil
+	}
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a duration, e.g. 2s or 1h", ErrInvalidValue, raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a boolean", ErrInvalidValue, raw)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %q is not an integer", ErrInvalidValue, raw)
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a positive integer", ErrInvalidValue, raw)
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(raw, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %q is not a number", ErrInvalidValue, raw)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("%w: unsupported type %s", ErrInvalidValue, field.Type())
+	}
+
+	return nil
+}
+
+// Decode sets the fields of the settings from raw values keyed by setting
+// name, converting each one to the type of its field. Keys that don't match
+// a setting are ignored, Load reports those of strict sources; every value
+// that can't be converted is reported.
+func Decode(values map[string]string, s *Settings) error {
+	problems := &ValidationError{}
+
+	for _, f := range settingsFields() {
+		raw, ok := values[f.Key]
+		if !ok {
+			continue
+		}
+		if err := setField(f.value(s), strings.TrimSpace(raw)); err != nil {
+			problems.add(f.Key, err)
+		}
+	}
+
+	return problems.orNil()
+}
+
+// DecodeJSON flattens a JSON object, such as a Secrets Manager secret, to raw
+// values.
+func DecodeJSON(data []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var object map[string]any
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("parsing json: %w", err)
+	}
+
+	return flatten(object)
+}
+
+// flatten converts a decoded JSON or YAML object to raw values. Nested objects
+// are joined to their parent key with an underscore, lists of scalars are joined
+// with commas and null values are skipped.
+func flatten(object map[string]any) (map[string]string, error) {
+	problems := &ValidationError{}
+	values := make(map[string]string, len(object))
+	flattenInto(values, "", object, problems)
+	if err := problems.orNil(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flattenInto(values map[string]string, prefix string, object map[string]any, problems *ValidationError) {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, name := range keys {
+		key := name
+		if prefix != "" {
+			key = prefix + "_" + name
+		}
+		switch value := object[name].(type) {
+		case nil:
+		case map[string]any:
+			flattenInto(values, key, value, problems)
+		case []any:
+			items := make([]string, 0, len(value))
+			for _, item := range value {
+				raw, ok := scalar(item)
+				if !ok {
+					problems.add(key, fmt.Errorf("%w: lists may only hold strings, numbers or booleans", ErrInvalidValue))
+					break
+				}
+				items = append(items, raw)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			raw, ok := scalar(value)
+			if !ok {
+				problems.add(key, fmt.Errorf("%w: must be a string, number or boolean", ErrInvalidValue))
+				continue
+			}
+			values[key] = raw
+		}
+	}
+}
+
+// scalar formats a decoded string, number or boolean.
+func scalar(value any) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case json.Number:
+		return value.String(), true
+	case bool:
+		return strconv.FormatBool(value), true
+	case int:
+		return strconv.Itoa(value), true
+	case int64:
+		return strconv.FormatInt(value, 10), true
+	case uint64:
+		return strconv.FormatUint(value, 10), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	case time.Time:
+		return value.Format(time.RFC3339), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]string
+		check   func(t *testing.T, s *Settings)
+		wantErr []string
+	}{
+		{
+			name: "typed values",
+			values: map[string]string{
+				"db_connect_timeout":      " 2s ",
+				"db_max_conns":            "10",
+				"db_refresh_password":     "true",
+				"db_time_zone":            "UTC",
+				"log_health_sample_every": "0",
+				"tracing_sample_ratio":    "0.25",
+			},
+			check: func(t *testing.T, s *Settings) {
+				if s.Database.ConnectTimeout != 2*time.Second || s.Database.MaxConns != 10 || !s.Database.RefreshPassword {
+					t.Errorf("db = %+v", s.Database)
+				}
+				if s.Database.TimeZone == nil || *s.Database.TimeZone != "UTC" {
+					t.Errorf("db_time_zone = %v", s.Database.TimeZone)
+				}
+				if s.Logging.HealthSampleEvery != 0 || s.Tracing.SampleRatio != 0.25 {
+					t.Errorf("log = %+v tracing = %+v", s.Logging, s.Tracing)
+				}
+			},
+		},
+		{
+			name:   "0 as a duration",
+			values: map[string]string{"log_slow_request": "0"},
+			check: func(t *testing.T, s *Settings) {
+				if s.Logging.SlowRequest != 0 {
+					t.Errorf("log_slow_request = %s", s.Logging.SlowRequest)
+				}
+			},
+		},
+		{
+			name: "every invalid value at once",
+			values: map[string]string{
+				"db_connect_timeout":      "15",
+				"db_max_conns":            "many",
+				"db_refresh_password":     "yes please",
+				"log_health_sample_every": "-1",
+				"tracing_sample_ratio":    "half",
+			},
+			wantErr: []string{"db_connect_timeout", "db_max_conns", "db_refresh_password", "log_health_sample_every", "tracing_sample_ratio"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var settings Settings
+			err := Decode(tt.values, &settings)
+
+			if tt.wantErr != nil {
+				var problems *ValidationError
+				if !errors.As(err, &problems) {
+					t.Fatalf("err = %v, want a *ValidationError", err)
+				}
+				var keys []string
+				for _, p := range problems.Problems {
+					keys = append(keys, p.Key)
+					if !errors.Is(p, ErrInvalidValue) {
+						t.Errorf("%s: %v is not ErrInvalidValue", p.Key, p)
+					}
+				}
+				if !reflect.DeepEqual(keys, tt.wantErr) {
+					t.Errorf("problems = %v, want %v", keys, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			tt.check(t, &settings)
+		})
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "flat",
+			data: `{"db_password": "secret", "db_max_conns": 10, "db_restore": false}`,
+			want: map[string]string{"db_password": "secret", "db_max_conns": "10", "db_restore": "false"},
+		},
+		{
+			name: "nested objects and lists",
+			data: `{"db": {"port": 3307, "time_zone": null}, "http": {"cors_origins": ["https://a.example.com", "https://b.example.com"]}, "tracing": {"sample_ratio": 0.5}}`,
+			want: map[string]string{
+				"db_port":              "3307",
+				"http_cors_origins":    "https://a.example.com,https://b.example.com",
+				"tracing_sample_ratio": "0.5",
+			},
+		},
+		{
+			name:    "lists of objects",
+			data:    `{"http": {"cors_origins": [{"origin": "https://a.example.com"}]}}`,
+			wantErr: "http_cors_origins",
+		},
+		{
+			name:    "not an object",
+			data:    `["db_password"]`,
+			wantErr: "parsing json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := DecodeJSON([]byte(tt.data))
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("DecodeJSON: %v", err)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("values = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+const dotEnvFile = ".env"
+
+// DotEnvFiles returns the dotenv files of the mode that exist, lowest precedence
+// first: .env, .env.<mode> and the uncommitted .env.local overrides. In local
+// mode .env.<mode> is .env.local, which is listed once.
+func DotEnvFiles(mode string) ([]string, error) {
+	local := dotEnvFile + ".local"
+	candidates := []string{dotEnvFile}
+	if file := dotEnvFile + "." + mode; file != local {
+		candidates = append(candidates, file)
+	}
+	candidates = append(candidates, local)
+
+	var files []string
+	for _, file := range candidates {
+		_, err := os.Stat(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("checking %s: %w", file, err)
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// DotEnvMode returns the mode set in the .env file, if any, for when the
+// environment doesn't set it.
+func DotEnvMode() string {
+	values, err := godotenv.Read(dotEnvFile)
+	if err != nil {
+		return ""
+	}
+	return values[EnvMode]
+}
+
+// ExportDotEnv sets the variables of the files that the process environment
+// doesn't set yet, later files taking precedence over earlier ones. The AWS SDK
+// and the other readers of the environment see them too.
+func ExportDotEnv(files []string) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	// godotenv.Load keeps the first value it sees, so highest precedence goes first.
+	reversed := make([]string, len(files))
+	for i, file := range files {
+		reversed[len(files)-1-i] = file
+	}
+	if err := godotenv.Load(reversed...); err != nil {
+		return fmt.Errorf("loading dotenv files: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDotEnvFiles(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	for _, file := range []string{".env", ".env.local", ".env.staging"} {
+		if err := os.WriteFile(file, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{mode: "local", want: []string{".env", ".env.local"}},
+		{mode: "staging", want: []string{".env", ".env.staging", ".env.local"}},
+		{mode: "prod", want: []string{".env", ".env.local"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			files, err := DotEnvFiles(tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(files, tt.want) {
+				t.Errorf("files = %v, want %v", files, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// field is one setting of Settings. Its key is the prefix of its section, the
+// json name of the section and an underscore, followed by its own json name.
+type field struct {
+	reflect.StructField
+	Key     string
+	Section string
+	// Alias is a former key still accepted, e.g. cors_origins for http_cors_origins.
+	Alias string
+}
+
+// settingsFields lists the settings in the order of the Settings fields.
+var settingsFields = sync.OnceValue(func() []field {
+	return structFields(reflect.TypeOf(Settings{}), "", "", nil)
+})
+
+func structFields(t reflect.Type, section, prefix string, index []int) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		f.Index = append(append([]int{}, index...), i)
+
+		if f.Type.Kind() == reflect.Struct && f.Type != timeType {
+			fields = append(fields, structFields(f.Type, jsonKey(f), prefix+jsonKey(f)+"_", f.Index)...)
+			continue
+		}
+		fields = append(fields, field{StructField: f, Key: prefix + jsonKey(f), Section: section, Alias: f.Tag.Get("alias")})
+	}
+	return fields
+}
+
+// value returns the field of the setting in s.
+func (f field) value(s *Settings) reflect.Value {
+	return reflect.ValueOf(s).Elem().FieldByIndex(f.Index)
+}
+
+// jsonKey returns the json name of a field.
+func jsonKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// Keys returns the keys of every setting, in the order of the Settings fields.
+func Keys() []string {
+	fields := settingsFields()
+	keys := make([]string, 0, len(fields))
+	for _, f := range fields {
+		keys = append(keys, f.Key)
+	}
+	return keys
+}
+
+// Sections returns the names of the sections of the settings, in the order of
+// the Settings fields.
+func Sections() []string {
+	var sections []string
+	for _, f := range settingsFields() {
+		if !slices.Contains(sections, f.Section) {
+			sections = append(sections, f.Section)
+		}
+	}
+	return sections
+}
+
+// unknownKeys returns the sorted keys of values that are neither a setting nor
+// the alias of one.
+func unknownKeys(values map[string]string) []string {
+	known := make(map[string]bool)
+	for _, f := range settingsFields() {
+		known[f.Key] = true
+		if f.Alias != "" {
+			known[f.Alias] = true
+		}
+	}
+	var unknown []string
+	for key := range values {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
+
+// renameAliases returns the values with those set under the former key of a
+// setting moved to its current key, unless the current key is set too. The
+// values of the source are left untouched.
+func renameAliases(source string, values map[string]string) map[string]string {
+	renamed := make(map[string]string, len(values))
+	for key, value := range values {
+		renamed[key] = value
+	}
+	for _, f := range settingsFields() {
+		value, ok := values[f.Alias]
+		if f.Alias == "" || !ok {
+			continue
+		}
+		if _, set := values[f.Key]; !set {
+			renamed[f.Key] = value
+			log.Warn().Msg(fmt.Sprintf("%s sets %s, which is deprecated, use %s", source, f.Alias, f.Key))
+		}
+		delete(renamed, f.Alias)
+	}
+	return renamed
+}
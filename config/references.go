@@ -0,0 +1,91 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const secretScheme = "secret://"
+
+var ErrUnresolvedSecret = errors.New("references a secret that could not be resolved")
+
+// secretReference points at one key of a secret, written
+// secret://<secret name>#<key>, e.g. secret://prod/template#db_password.
+type secretReference struct {
+	name string
+	key  string
+}
+
+func parseSecretReference(value string) (secretReference, bool) {
+	rest, ok := strings.CutPrefix(value, secretScheme)
+	if !ok {
+		return secretReference{}, false
+	}
+	name, key, _ := strings.Cut(rest, "#")
+	return secretReference{name: name, key: key}, true
+}
+
+func (r secretReference) String() string {
+	return secretScheme + r.name + "#" + r.key
+}
+
+// resolveSecretReferences replaces the settings referencing a secret with the
+// referenced value, fetching each secret once. Every reference that can't be
+// resolved is reported, none is left as is.
+func resolveSecretReferences(ctx context.Context, provider SecretProvider, values, origins map[string]string) error {
+	problems := &ValidationError{}
+	secrets := make(map[string]map[string]string)
+	failures := make(map[string]error)
+
+	for _, f := range settingsFields() {
+		key := f.Key
+		ref, ok := parseSecretReference(values[key])
+		if !ok {
+			continue
+		}
+
+		fail := func(err error) {
+			problems.Problems = append(problems.Problems, &FieldError{
+				Key:    key,
+				Source: origins[key],
+				Err:    fmt.Errorf("%w: %s: %v", ErrUnresolvedSecret, ref, err),
+			})
+		}
+		switch {
+		case ref.name == "" || ref.key == "":
+			fail(errors.New("expected secret://<secret name>#<key>"))
+			continue
+		case provider == nil:
+			fail(errors.New("no secret provider is configured"))
+			continue
+		}
+
+		secret, fetched := secrets[ref.name]
+		if !fetched {
+			if err, failed := failures[ref.name]; failed {
+				fail(err)
+				continue
+			}
+			var err error
+			secret, err = provider.GetSecret(ctx, ref.name)
+			if err != nil {
+				failures[ref.name] = err
+				fail(err)
+				continue
+			}
+			secrets[ref.name] = secret
+		}
+
+		value, ok := secret[ref.key]
+		if !ok {
+			fail(fmt.Errorf("%s has no key %s", ref.name, ref.key))
+			continue
+		}
+		values[key] = value
+		origins[key] = fmt.Sprintf("%s (%s via %s)", origins[key], ref, provider.Name())
+	}
+
+	return problems.orNil()
+}
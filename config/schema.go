@@ -0,0 +1,158 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+const generatedNotice = "Generated from config.Settings by `go run . config generate`, do not edit."
+
+// section is one top-level section of Settings with its settings.
+type section struct {
+	name   string
+	fields []field
+}
+
+func settingsSections() []section {
+	t := reflect.TypeOf(Settings{})
+	sections := make([]section, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		s := section{name: jsonKey(t.Field(i))}
+		for _, f := range settingsFields() {
+			if f.Index[0] == i {
+				s.fields = append(s.fields, f)
+			}
+		}
+		sections = append(sections, s)
+	}
+	return sections
+}
+
+// EnvTemplate returns a dotenv file listing the bootstrap variables, commented
+// out, then every setting with its description and default value.
+func EnvTemplate() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# %s\n", generatedNotice)
+	b.WriteString("\n# --- bootstrap, read before the settings to find their sources ---\n")
+	for _, v := range BootstrapVariables {
+		fmt.Fprintf(&b, "\n# %s\n# %s=%s\n", v.Description, v.Name, v.Default)
+	}
+	for _, s := range settingsSections() {
+		fmt.Fprintf(&b, "\n# --- %s ---\n", s.name)
+		for _, f := range s.fields {
+			b.WriteString("\n")
+			if description := f.Tag.Get("description"); description != "" {
+				fmt.Fprintf(&b, "# %s\n", description)
+			}
+			if f.Tag.Get("required") == "true" {
+				b.WriteString("# Required.\n")
+			}
+			if f.Tag.Get("secret") == "true" {
+				b.WriteString("# Secret, prefer a secret://<secret name>#<key> reference.\n")
+			}
+			fmt.Fprintf(&b, "%s=%s\n", f.Key, f.Tag.Get("default"))
+		}
+	}
+	return b.Bytes()
+}
+
+type schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	AnyOf                []*schema          `json:"anyOf,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	WriteOnly            bool               `json:"writeOnly,omitempty"`
+}
+
+// durationPattern matches the durations accepted by time.ParseDuration,
+// including a bare 0.
+const durationPattern = `^(0|([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// JSONSchema returns the JSON Schema of the config file given with --config.
+// Required settings aren't required by the schema, since another source may
+// set them, and secrets are marked write only.
+func JSONSchema() ([]byte, error) {
+	closed := false
+	root := &schema{
+		Schema:               "https://json-schema.org/draft/2020-12/schema",
+		Title:                "Settings",
+		Description:          generatedNotice,
+		Type:                 "object",
+		Properties:           map[string]*schema{},
+		AdditionalProperties: &closed,
+	}
+
+	for _, s := range settingsSections() {
+		sectionSchema := &schema{
+			Type:                 "object",
+			Properties:           map[string]*schema{},
+			AdditionalProperties: &closed,
+		}
+		for _, f := range s.fields {
+			property, err := fieldSchema(f)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Key, err)
+			}
+			sectionSchema.Properties[jsonKey(f.StructField)] = property
+		}
+		root.Properties[s.name] = sectionSchema
+	}
+
+	out, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+func fieldSchema(f field) (*schema, error) {
+	property := &schema{
+		Description: f.Tag.Get("description"),
+		WriteOnly:   f.Tag.Get("secret") == "true",
+	}
+	if f.Tag.Get("required") == "true" {
+		property.Description += " (required)"
+	}
+
+	t := f.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == durationType:
+		// A bare 0 is read as an integer by YAML and JSON.
+		property.AnyOf = []*schema{{Type: "string", Pattern: durationPattern}, {Type: "integer", Enum: []any{0}}}
+	case t.Kind() == reflect.String:
+		property.Type = "string"
+	case t.Kind() == reflect.Bool:
+		property.Type = "boolean"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		property.Type = "integer"
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		property.Type = "number"
+	default:
+		return nil, fmt.Errorf("unsupported type %s", f.Type)
+	}
+
+	if def, ok := f.Tag.Lookup("default"); ok {
+		if property.Type == "string" || t == durationType {
+			property.Default = def
+		} else {
+			value := reflect.New(t).Elem()
+			if err := setField(value, def); err != nil {
+				return nil, err
+			}
+			property.Default = value.Interface()
+		}
+	}
+
+	return property, nil
+}
@@ -0,0 +1,200 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/rs/zerolog/log"
+)
+
+// Secret providers selectable with NewSecretProvider.
+const (
+	SecretsManager = "secretsmanager"
+	ParameterStore = "ssm"
+	SecretFile     = "file"
+)
+
+// SecretProvider fetches a named secret as raw values keyed by setting name.
+type SecretProvider interface {
+	// Name identifies the provider in logs and the provenance report.
+	Name() string
+	GetSecret(ctx context.Context, name string) (map[string]string, error)
+}
+
+// AWSConfigLoader returns the AWS config the AWS secret providers build their
+// client from.
+type AWSConfigLoader func(ctx context.Context) (aws.Config, error)
+
+// NewSecretProvider returns the provider of the kind, one of SecretsManager,
+// ParameterStore or SecretFile. The AWS providers load the AWS config when they
+// read their first secret, so none is needed until a secret is read.
+func NewSecretProvider(kind string, loadAWSConfig AWSConfigLoader) (SecretProvider, error) {
+	switch kind {
+	case SecretsManager:
+		return NewSecretsManagerProvider(loadAWSConfig), nil
+	case ParameterStore:
+		return NewParameterStoreProvider(loadAWSConfig), nil
+	case SecretFile:
+		return NewFileSecretProvider(), nil
+	default:
+		return nil, fmt.Errorf("%q: no such secret provider, use %s, %s or %s", kind, SecretsManager, ParameterStore, SecretFile)
+	}
+}
+
+// lazyClient builds an AWS client from the AWS config on first use. A failure
+// to load the config is returned and retried on the next use.
+type lazyClient[T any] struct {
+	loadConfig AWSConfigLoader
+	build      func(aws.Config) T
+
+	mu     sync.Mutex
+	client *T
+}
+
+func newLazyClient[T any](loadConfig AWSConfigLoader, build func(aws.Config) T) *lazyClient[T] {
+	return &lazyClient[T]{loadConfig: loadConfig, build: build}
+}
+
+func (c *lazyClient[T]) get(ctx context.Context) (T, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.client == nil {
+		awsCfg, err := c.loadConfig(ctx)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		client := c.build(awsCfg)
+		c.client = &client
+	}
+	return *c.client, nil
+}
+
+type secretsManagerProvider struct {
+	client *lazyClient[*secretsmanager.Client]
+}
+
+// NewSecretsManagerProvider reads JSON secrets from AWS Secrets Manager, the
+// name being the secret id.
+func NewSecretsManagerProvider(loadAWSConfig AWSConfigLoader) SecretProvider {
+	return secretsManagerProvider{client: newLazyClient(loadAWSConfig, func(awsCfg aws.Config) *secretsmanager.Client {
+		return secretsmanager.NewFromConfig(awsCfg)
+	})}
+}
+
+func (p secretsManagerProvider) Name() string { return "secrets manager" }
+
+func (p secretsManagerProvider) GetSecret(ctx context.Context, name string) (map[string]string, error) {
+	client, err := p.client.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sv, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting secret %s: %w", name, err)
+	}
+
+	if sv.SecretString == nil {
+		return nil, fmt.Errorf("could not retrieve secret: %s", name)
+	}
+
+	values, err := DecodeJSON([]byte(*sv.SecretString))
+	if err != nil {
+		return nil, fmt.Errorf("secret %s: %w", name, err)
+	}
+	return values, nil
+}
+
+type parameterStoreProvider struct {
+	client *lazyClient[*ssm.Client]
+}
+
+// NewParameterStoreProvider reads the parameters under a path of the AWS SSM
+// Parameter Store, decrypting SecureStrings. The name is the path, e.g.
+// /prod/template, and each parameter below it is a setting named after the
+// rest of its path, slashes replaced by underscores.
+func NewParameterStoreProvider(loadAWSConfig AWSConfigLoader) SecretProvider {
+	return parameterStoreProvider{client: newLazyClient(loadAWSConfig, func(awsCfg aws.Config) *ssm.Client {
+		return ssm.NewFromConfig(awsCfg)
+	})}
+}
+
+func (p parameterStoreProvider) Name() string { return "parameter store" }
+
+func (p parameterStoreProvider) GetSecret(ctx context.Context, name string) (map[string]string, error) {
+	client, err := p.client.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSuffix(name, "/") + "/"
+	values := make(map[string]string)
+
+	paginator := ssm.NewGetParametersByPathPaginator(client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting parameters %s: %w", name, err)
+		}
+		for _, parameter := range page.Parameters {
+			key := strings.ReplaceAll(strings.TrimPrefix(aws.ToString(parameter.Name), path), "/", "_")
+			values[key] = aws.ToString(parameter.Value)
+		}
+	}
+
+	if len(values) == 0 {
+		return nil, fmt.Errorf("could not retrieve parameters: no parameter under %s", name)
+	}
+	return values, nil
+}
+
+type fileSecretProvider struct{}
+
+// NewFileSecretProvider reads secrets from YAML or JSON files, the name being
+// the path of the file. It stands in for the AWS providers in tests and
+// outside AWS.
+func NewFileSecretProvider() SecretProvider {
+	return fileSecretProvider{}
+}
+
+func (p fileSecretProvider) Name() string { return "file" }
+
+func (p fileSecretProvider) GetSecret(ctx context.Context, name string) (map[string]string, error) {
+	return FileSource(name).Values(ctx)
+}
+
+type secretSource struct {
+	provider SecretProvider
+	name     string
+}
+
+// SecretSource reads every key of one secret of the provider. Several secrets
+// are merged by passing a source for each, later ones taking precedence.
+func SecretSource(provider SecretProvider, name string) Source {
+	return secretSource{provider: provider, name: name}
+}
+
+func (s secretSource) Name() string { return s.provider.Name() + " " + s.name }
+
+func (s secretSource) Values(ctx context.Context) (map[string]string, error) {
+	log.Info().Msg(fmt.Sprintf("Trying to get secret: %s\n", s.Name()))
+	values, err := s.provider.GetSecret(ctx, s.name)
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Msg(fmt.Sprintf("Got secret: %s\n", s.Name()))
+	return values, nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSecretProviderLoadsAWSConfigOnFirstRead(t *testing.T) {
+	for _, kind := range []string{SecretsManager, ParameterStore} {
+		t.Run(kind, func(t *testing.T) {
+			loads := 0
+			provider, err := NewSecretProvider(kind, func(context.Context) (aws.Config, error) {
+				loads++
+				return aws.Config{}, errors.New("no AWS credentials")
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, _, err := Load(context.Background(), provider, Sections(), StaticSource("env", requiredValues())); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if loads != 0 {
+				t.Errorf("loaded the AWS config %d times without a secret to read", loads)
+			}
+
+			reference := StaticSource("env", withValues(requiredValues(), map[string]string{"db_password": "secret://dev/template#db_password"}))
+			for i := 1; i <= 2; i++ {
+				_, _, err := Load(context.Background(), provider, Sections(), reference)
+				if !errors.Is(err, ErrUnresolvedSecret) {
+					t.Fatalf("err = %v, want ErrUnresolvedSecret", err)
+				}
+				if loads != i {
+					t.Errorf("loaded the AWS config %d times after %d loads, want a retry each", loads, i)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Source supplies raw setting values keyed by setting name. Sources are read
+// again on every load, so they should not cache what they return.
+type Source interface {
+	// Name identifies the source in the provenance report, e.g. "env" or "file config.yaml".
+	Name() string
+	Values(ctx context.Context) (map[string]string, error)
+}
+
+type staticSource struct {
+	name   string
+	values map[string]string
+}
+
+// StaticSource returns a source always supplying values, such as a snapshot of
+// the process environment or the settings set on the command line.
+func StaticSource(name string, values map[string]string) Source {
+	return staticSource{name: name, values: values}
+}
+
+func (s staticSource) Name() string { return s.name }
+
+func (s staticSource) Values(_ context.Context) (map[string]string, error) {
+	return s.values, nil
+}
+
+// strict is implemented by the sources whose every key must be a setting or
+// the alias of one. Load reports their other keys, which are likely misspelled
+// settings, rather than ignoring them.
+type strict interface {
+	strict()
+}
+
+type strictSource struct {
+	Source
+}
+
+// StrictSource makes Load reject the keys of source that are not settings,
+// such as those set on the command line. The environment is not strict, it
+// holds unrelated variables.
+func StrictSource(source Source) Source {
+	return strictSource{Source: source}
+}
+
+func (strictSource) strict() {}
+
+type fileSource struct {
+	path string
+}
+
+// FileSource reads a YAML or JSON file, chosen by its extension. Nested objects
+// are flattened by joining the keys with an underscore. The file is strict, see
+// StrictSource.
+func FileSource(path string) Source {
+	return fileSource{path: path}
+}
+
+func (s fileSource) Name() string { return "file " + s.path }
+
+func (fileSource) strict() {}
+
+func (s fileSource) Values(_ context.Context) (map[string]string, error) {
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	switch filepath.Ext(s.path) {
+	case ".json":
+		values, err := DecodeJSON(content)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", s.path, err)
+		}
+		return values, nil
+	case ".yaml", ".yml":
+		var object map[string]any
+		if err := yaml.Unmarshal(content, &object); err != nil {
+			return nil, fmt.Errorf("config file %s: parsing yaml: %w", s.path, err)
+		}
+		values, err := flatten(object)
+		if err != nil {
+			return nil, fmt.Errorf("config file %s: %w", s.path, err)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, use .yaml, .yml or .json", s.path)
+	}
+}
+
+type dotEnvSource struct {
+	path string
+}
+
+// DotEnvSource reads a dotenv file.
+func DotEnvSource(path string) Source {
+	return dotEnvSource{path: path}
+}
+
+func (s dotEnvSource) Name() string { return "dotenv " + s.path }
+
+func (s dotEnvSource) Values(_ context.Context) (map[string]string, error) {
+	values, err := godotenv.Read(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", s.path, err)
+	}
+	return values, nil
+}
+
+// Names of the bootstrap variables, read from the environment and the dotenv
+// files before the settings to find their sources.
+const (
+	EnvMode            = "mode"
+	EnvConfigFile      = "config_file"
+	EnvSecretsProvider = "secrets_provider"
+	EnvSecretNames     = "secret_names"
+)
+
+// BootstrapVariable is a variable read outside Settings, listed in the
+// generated .env.template.
+type BootstrapVariable struct {
+	Name        string
+	Description string
+	Default     string
+}
+
+// BootstrapVariables lists the bootstrap variables in the order they are read.
+var BootstrapVariables = []BootstrapVariable{
+	{EnvMode, "Mode to run in, selects the .env.<mode> file and the default secret", "local"},
+	{EnvConfigFile, "YAML or JSON settings file, layered under the dotenv files and the environment", ""},
+	{EnvSecretsProvider, "Provider of the secrets: secretsmanager, ssm or file", SecretsManager},
+	{EnvSecretNames, "Comma separated secrets layered over the environment, <mode>/template by default outside local mode", ""},
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrRequired     = errors.New("is required")
+	ErrOutOfRange   = errors.New("is out of range")
+	ErrInvalidValue = errors.New("is invalid")
+)
+
+// FieldError describes a problem with one setting, Key is its json name and
+// Source the source of its value when known.
+type FieldError struct {
+	Key    string
+	Source string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s %v (from %s)", e.Key, e.Err, e.Source)
+	}
+	return fmt.Sprintf("%s %v", e.Key, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every problem found while loading the settings, so
+// they can all be fixed at once. errors.Is matches the errors of each problem.
+type ValidationError struct {
+	Problems []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		problems = append(problems, p.Error())
+	}
+	return fmt.Sprintf("invalid settings: %s", strings.Join(problems, "; "))
+}
+
+// List returns the problems one per line, for people to read.
+func (e *ValidationError) List() string {
+	var b strings.Builder
+	for _, p := range e.Problems {
+		b.WriteString("  - ")
+		b.WriteString(p.Error())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		errs = append(errs, p)
+	}
+	return errs
+}
+
+func (e *ValidationError) add(key string, err error) {
+	e.Problems = append(e.Problems, &FieldError{Key: key, Err: err})
+}
+
+func (e *ValidationError) orNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks the required settings of the given sections, those the
+// command uses, and the values of every setting, and returns a
+// *ValidationError listing every problem.
+func (s *Settings) Validate(sections ...string) error {
+	problems := &ValidationError{}
+
+	for _, f := range settingsFields() {
+		if f.Tag.Get("required") == "true" && slices.Contains(sections, f.Section) && f.value(s).IsZero() {
+			problems.add(f.Key, ErrRequired)
+		}
+	}
+
+	if s.HTTP.CorsOrigins != "" {
+		if err := ValidateAllowedOrigins(s.HTTP.AllowedOrigins()); err != nil {
+			problems.add("http_cors_origins", fmt.Errorf("%w: %v", ErrInvalidValue, err))
+		}
+	}
+	if _, err := s.HTTP.Deprecations(); err != nil {
+		problems.add("http_deprecated_versions", fmt.Errorf("%w: %v", ErrInvalidValue, err))
+	}
+
+	db := s.Database
+	if db.Port != "" {
+		if port, err := strconv.Atoi(db.Port); err != nil || port < 1 || port > 65535 {
+			problems.add("db_port", fmt.Errorf("%w: must be a port number between 1 and 65535", ErrOutOfRange))
+		}
+	}
+	if db.MaxConns < 1 {
+		problems.add("db_max_conns", fmt.Errorf("%w: must be at least 1", ErrOutOfRange))
+	}
+	if db.MinConns < 0 {
+		problems.add("db_min_conns", fmt.Errorf("%w: must not be negative", ErrOutOfRange))
+	}
+	if db.MinConns > db.MaxConns {
+		problems.add("db_min_conns", fmt.Errorf("%w: must not be greater than db_max_conns (%d)", ErrOutOfRange, db.MaxConns))
+	}
+	if db.ConnectTimeout <= 0 {
+		problems.add("db_connect_timeout", fmt.Errorf("%w: must be positive", ErrOutOfRange))
+	}
+	if db.MaxConnLifetime < 0 {
+		problems.add("db_max_conn_life_time", fmt.Errorf("%w: must not be negative", ErrOutOfRange))
+	}
+
+	if _, err := zerolog.ParseLevel(s.Logging.Level); err != nil {
+		problems.add("log_level", fmt.Errorf("%w: %q is not a log level", ErrInvalidValue, s.Logging.Level))
+	}
+
+	if s.Logging.SlowRequest < 0 {
+		problems.add("log_slow_request", fmt.Errorf("%w: must not be negative", ErrOutOfRange))
+	}
+
+	if s.Tracing.SampleRatio < 0 || s.Tracing.SampleRatio > 1 {
+		problems.add("tracing_sample_ratio", fmt.Errorf("%w: must be between 0 and 1", ErrOutOfRange))
+	}
+
+	return problems.orNil()
+}
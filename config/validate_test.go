@@ -0,0 +1,121 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// validSettings returns settings with the defaults and the required settings
+// of every section.
+func validSettings(t *testing.T) *Settings {
+	t.Helper()
+	settings := &Settings{}
+	if err := applyDefaults(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := Decode(requiredValues(), settings); err != nil {
+		t.Fatal(err)
+	}
+	return settings
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		sections []string
+		modify   func(s *Settings)
+		want     []string
+		wantErr  error
+	}{
+		{
+			name:   "valid",
+			modify: func(s *Settings) {},
+		},
+		{
+			name:     "required settings of the sections only",
+			sections: []string{SectionHTTP},
+			modify:   func(s *Settings) { s.Database.User = ""; s.Database.Name = "" },
+		},
+		{
+			name:     "missing required settings",
+			sections: []string{SectionHTTP, SectionDatabase},
+			modify:   func(s *Settings) { s.HTTP.CorsOrigins = ""; s.Database.Name = "" },
+			want:     []string{"http_cors_origins", "db_database"},
+			wantErr:  ErrRequired,
+		},
+		{
+			name:    "wildcard origin",
+			modify:  func(s *Settings) { s.HTTP.CorsOrigins = "https://app.example.com, *" },
+			want:    []string{"http_cors_origins"},
+			wantErr: ErrInvalidValue,
+		},
+		{
+			name:    "deprecated versions",
+			modify:  func(s *Settings) { s.HTTP.DeprecatedVersions = "v1@tomorrow" },
+			want:    []string{"http_deprecated_versions"},
+			wantErr: ErrInvalidValue,
+		},
+		{
+			name:    "port out of range",
+			modify:  func(s *Settings) { s.Database.Port = "65536" },
+			want:    []string{"db_port"},
+			wantErr: ErrOutOfRange,
+		},
+		{
+			name:    "more idle than open connections",
+			modify:  func(s *Settings) { s.Database.MinConns = 6 },
+			want:    []string{"db_min_conns"},
+			wantErr: ErrOutOfRange,
+		},
+		{
+			name: "durations",
+			modify: func(s *Settings) {
+				s.Database.ConnectTimeout = 0
+				s.Database.MaxConnLifetime = -1
+			},
+			want:    []string{"db_connect_timeout", "db_max_conn_life_time"},
+			wantErr: ErrOutOfRange,
+		},
+		{
+			name:    "log level",
+			modify:  func(s *Settings) { s.Logging.Level = "verbose" },
+			want:    []string{"log_level"},
+			wantErr: ErrInvalidValue,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := validSettings(t)
+			tt.modify(settings)
+			sections := tt.sections
+			if sections == nil {
+				sections = Sections()
+			}
+
+			err := settings.Validate(sections...)
+
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			var problems *ValidationError
+			if !errors.As(err, &problems) {
+				t.Fatalf("err = %v, want a *ValidationError", err)
+			}
+			var keys []string
+			for _, p := range problems.Problems {
+				keys = append(keys, p.Key)
+				if !errors.Is(p, tt.wantErr) {
+					t.Errorf("%s: %v, want %v", p.Key, p, tt.wantErr)
+				}
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("problems = %v, want %v", keys, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Subscriber is notified when a reload changed the settings, with the keys of
+// the changed settings. Subscribers must not modify the settings.
+type Subscriber func(ctx context.Context, previous, current *Settings, changed []string) error
+
+type subscription struct {
+	name string
+	fn   Subscriber
+	// applied is the last settings the subscriber applied successfully, it is
+	// notified of the changes since then until it succeeds.
+	applied *Settings
+}
+
+// Watcher reloads the settings from their sources and notifies the
+// subscribers of the changes. Invalid settings are logged and ignored, the
+// previous ones stay current.
+type Watcher struct {
+	secrets  SecretProvider
+	sections []string
+	sources  []Source
+
+	// reloading serializes the reloads, which update the subscriptions.
+	reloading   sync.Mutex
+	mu          sync.RWMutex
+	current     *Settings
+	loadedAt    time.Time
+	subscribers []*subscription
+}
+
+// NewWatcher watches the settings loaded with Load from the same secrets
+// provider, sections and sources.
+func NewWatcher(current *Settings, secrets SecretProvider, sections []string, sources ...Source) *Watcher {
+	return &Watcher{
+		secrets:  secrets,
+		sections: sections,
+		sources:  sources,
+		current:  current,
+		loadedAt: time.Now(),
+	}
+}
+
+// LoadedAt returns when the settings were last loaded successfully, whether
+// they changed or not.
+func (w *Watcher) LoadedAt() time.Time {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.loadedAt
+}
+
+// Current returns the latest valid settings every subscriber applied.
+func (w *Watcher) Current() *Settings {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Subscribe registers fn to be notified of the changes to the current
+// settings, in registration order.
+func (w *Watcher) Subscribe(name string, fn Subscriber) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.subscribers = append(w.subscribers, &subscription{name: name, fn: fn, applied: w.current})
+}
+
+// Reload loads the settings once and notifies each subscriber of the changes
+// since the settings it last applied, so a subscriber that failed gets them
+// again on the next reload. The settings become current once every subscriber
+// applied them. It returns the keys changed since the current settings, and
+// the errors of the load or of the subscribers.
+func (w *Watcher) Reload(ctx context.Context) ([]string, error) {
+	w.reloading.Lock()
+	defer w.reloading.Unlock()
+
+	settings, _, err := Load(ctx, w.secrets, w.sections, w.sources...)
+	if err != nil {
+		return nil, fmt.Errorf("reloading settings: %w", err)
+	}
+
+	w.mu.Lock()
+	w.loadedAt = time.Now()
+	changed := Changes(w.current, settings)
+	subscribers := append([]*subscription{}, w.subscribers...)
+	w.mu.Unlock()
+
+	if len(changed) > 0 {
+		log.Info().Strs("changed", changed).Msg("Settings changed")
+	}
+	var errs []error
+	for _, s := range subscribers {
+		pending := Changes(s.applied, settings)
+		if len(pending) == 0 {
+			continue
+		}
+		if err := s.fn(ctx, s.applied, settings, pending); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.name, err))
+			continue
+		}
+		s.applied = settings
+	}
+
+	if len(errs) > 0 {
+		return changed, errors.Join(errs...)
+	}
+	if len(changed) > 0 {
+		w.mu.Lock()
+		w.current = settings
+		w.mu.Unlock()
+	}
+	return changed, nil
+}
+
+// Watch reloads the settings every interval until ctx is done.
+func (w *Watcher) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := w.Reload(ctx); err != nil {
+				log.Error().Err(err).Msg("failed to apply reloaded settings")
+			}
+		}
+	}
+}
+
+// Changed reports whether a setting or a section differs between the previous
+// and current settings of a subscriber, e.g. Changed(previous.Database,
+// current.Database). Comparing fields rather than keys makes a renamed setting
+// fail to compile instead of never matching.
+func Changed[T any](previous, current T) bool {
+	return !reflect.DeepEqual(previous, current)
+}
+
+// Changes returns the keys of the settings that differ between a and b.
+func Changes(a, b *Settings) []string {
+	var changed []string
+	for _, f := range settingsFields() {
+		if !reflect.DeepEqual(f.value(a).Interface(), f.value(b).Interface()) {
+			changed = append(changed, f.Key)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"sync/atomic"
+
+	mysqlDriver "github.com/go-sql-driver/mysql"
+)
+
+// dsnConnector opens connections with a DSN that can be swapped while the pool
+// is in use, so new credentials apply without replacing the *sql.DB the
+// repositories hold.
+type dsnConnector struct {
+	connector atomic.Value // driver.Connector
+}
+
+func newDSNConnector(dsn string) (*dsnConnector, error) {
+	c := &dsnConnector{}
+	if err := c.setDSN(dsn); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *dsnConnector) setDSN(dsn string) error {
+	cfg, err := mysqlDriver.ParseDSN(dsn)
+	if err != nil {
+		return fmt.Errorf("parsing connection string: %w", err)
+	}
+	connector, err := mysqlDriver.NewConnector(cfg)
+	if err != nil {
+		return err
+	}
+	c.connector.Store(connector)
+	return nil
+}
+
+func (c *dsnConnector) current() driver.Connector {
+	return c.connector.Load().(driver.Connector)
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.current().Connect(ctx)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.current().Driver()
+}
@@ -3,15 +3,17 @@ package mysql
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"template/config"
-	"time"
 
+	"github.com/XSAM/otelsql"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/feature/rds/auth"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/rs/zerolog/log"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 )
 
 const (
@@ -21,41 +23,26 @@ const (
 
 type DBConfig struct {
 	SkipMigrations bool `default:"false"`
-	Settings       config.Settings
-}
-
-var connectTimeout = 15 * time.Second
-
-type Config struct {
-	Host            string        `json:"db_host"`
-	HostRead        string        `json:"db_host_read"`
-	Port            string        `json:"db_port" default:"3306"`
-	Username        string        `json:"db_user" required:"true"`
-	Password        string        `json:"db_password"`
-	Database        string        `json:"db_database" required:"true"`
-	ConnectTimeout  time.Duration `json:"db_connect_timeout" default:"2s"`
-	MaxConnLifetime time.Duration `json:"db_max_conn_life_time" default:"1h"`
-	MaxConns        int           `json:"db_max_conns" default:"5"`
-	MinConns        int           `json:"db_min_conns" default:"5"`
-	RefreshPassword bool          `json:"db_refresh_password" default:"false"`
-	TZ              *string       `json:"db_time_zone"`
+	Database       config.DatabaseSettings
+	// Migrations are applied after the core migrations, in order.
+	Migrations []MigrationSource
 }
 
 func (cfg DBConfig) EffectivePassword(ctx context.Context, awsConfig aws.Config) (string, error) {
 	//TODO Rod - I believe this is never true because the calling method already checks if the password is empty?
-	if cfg.Settings.Password != "" {
-		return cfg.Settings.Password, nil
+	if cfg.Database.Password != "" {
+		return cfg.Database.Password, nil
 	}
 
 	return auth.BuildAuthToken(ctx,
-		fmt.Sprintf("%s:%s", cfg.Settings.Host, cfg.Settings.Port),
-		awsConfig.Region, cfg.Settings.Username, awsConfig.Credentials,
+		cfg.Database.Address(),
+		awsConfig.Region, cfg.Database.User, awsConfig.Credentials,
 	)
 }
 
 func (cfg DBConfig) ConnectionString(ctx context.Context, awsConfig aws.Config, hostType string) (string, error) {
 
-	password := cfg.Settings.Password
+	password := cfg.Database.Password
 	if len(password) == 0 {
 		var err error
 		password, err = cfg.EffectivePassword(ctx, awsConfig)
@@ -64,11 +51,10 @@ func (cfg DBConfig) ConnectionString(ctx context.Context, awsConfig aws.Config,
 		}
 	}
 
-	// host := switchDBHost(cfg.Settings, hostType)
-	cfg.Settings.ConnectTimeout = connectTimeout
+	// host := switchDBHost(cfg.Database, hostType)
 
-	connString := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?timeout=%ds&allowCleartextPasswords=true", cfg.Settings.Username, password, cfg.Settings.Host, cfg.Settings.Port, cfg.Settings.Database,
-		int(cfg.Settings.ConnectTimeout.Seconds()))
+	connString := fmt.Sprintf("%s:%s@tcp(%s)/%s?timeout=%s&allowCleartextPasswords=true&parseTime=true", cfg.Database.User, password, cfg.Database.Address(), cfg.Database.Name,
+		cfg.Database.ConnectTimeout)
 
 	return connString, nil
 }
@@ -77,57 +63,151 @@ func (cfg DBConfig) ConnectionString(ctx context.Context, awsConfig aws.Config,
 type DB struct {
 	Pool     *sql.DB
 	PoolRead *sql.DB
-	cfg      config.Settings
+	// cfg holds the settings the pools use, shared by the copies of the DB so
+	// that Reconfigure can restore them.
+	cfg *config.DatabaseSettings
+
+	connector     *dsnConnector
+	connectorRead *dsnConnector
 }
 
 // NewDB creates a pooldb to initialize a database connection and run migrations
 func NewDB(ctx context.Context, cfg DBConfig, awsConfig aws.Config) (DB, error) {
 
-	log.Info().Msg(fmt.Sprintf("Creating new db connection: host: %s port: %s", cfg.Settings.Host, cfg.Settings.Port))
-	dbPool, err := newPool(ctx, cfg, awsConfig, HostWrite)
+	log.Info().Msg(fmt.Sprintf("Creating new db connection: host: %s port: %s", cfg.Database.Host, cfg.Database.Port))
+	dbPool, connector, err := newPool(ctx, cfg, awsConfig, HostWrite)
 	if err != nil {
 		return DB{}, err
 	}
 
-	dbPoolRead, err := newPool(ctx, cfg, awsConfig, HostRead)
+	dbPoolRead, connectorRead, err := newPool(ctx, cfg, awsConfig, HostRead)
 	if err != nil {
 		return DB{}, err
 	}
 
+	settings := cfg.Database
 	return DB{
-		Pool:     dbPool,
-		PoolRead: dbPoolRead,
-		cfg:      cfg.Settings,
+		Pool:          dbPool,
+		PoolRead:      dbPoolRead,
+		cfg:           &settings,
+		connector:     connector,
+		connectorRead: connectorRead,
 	}, nil
 }
 
-func newPool(ctx context.Context, cfg DBConfig, awsConfig aws.Config, hostType string) (*sql.DB, error) {
+// Reconfigure points both pools at new settings, e.g. rotated credentials,
+// without replacing them. Idle connections are closed so the next ones are
+// opened with the new settings, which are checked with a ping; when either
+// pool fails, both are restored to the previous settings.
+func (db DB) Reconfigure(ctx context.Context, cfg DBConfig, awsConfig aws.Config) error {
+	type pool struct {
+		name      string
+		db        *sql.DB
+		connector *dsnConnector
+		hostType  string
+		dsn       string
+		previous  driver.Connector
+	}
+	var pools []*pool
+	for _, p := range []*pool{
+		{name: "write", db: db.Pool, connector: db.connector, hostType: HostWrite},
+		{name: "read", db: db.PoolRead, connector: db.connectorRead, hostType: HostRead},
+	} {
+		if p.connector == nil {
+			continue
+		}
+		connStr, err := cfg.ConnectionString(ctx, awsConfig, p.hostType)
+		if err != nil {
+			return fmt.Errorf("%s pool: %w", p.name, err)
+		}
+		p.dsn = connStr
+		pools = append(pools, p)
+	}
+
+	var switched []*pool
+	rollback := func() {
+		for _, p := range switched {
+			p.connector.connector.Store(p.previous)
+			p.db.SetMaxIdleConns(0)
+			configurePool(p.db, DBConfig{Database: *db.cfg})
+		}
+	}
+	for _, p := range pools {
+		p.previous = p.connector.current()
+		if err := p.connector.setDSN(p.dsn); err != nil {
+			rollback()
+			return fmt.Errorf("%s pool: %w", p.name, err)
+		}
+		switched = append(switched, p)
+
+		// Dropping the idle connections forces the ping to open a new one.
+		p.db.SetMaxIdleConns(0)
+		configurePool(p.db, cfg)
+		if err := p.db.PingContext(ctx); err != nil {
+			rollback()
+			return fmt.Errorf("%s pool: ping with the new settings failed, keeping the previous ones: %w", p.name, err)
+		}
+	}
+	*db.cfg = cfg.Database
+
+	log.Info().Msg(fmt.Sprintf("Reconnected to the database: host: %s port: %s", cfg.Database.Host, cfg.Database.Port))
+	return nil
+}
+
+// Close closes both the write and read pools.
+func (db DB) Close() error {
+	var errs []error
+	if db.Pool != nil {
+		if err := db.Pool.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing write pool: %w", err))
+		}
+	}
+	if db.PoolRead != nil {
+		if err := db.PoolRead.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing read pool: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func newPool(ctx context.Context, cfg DBConfig, awsConfig aws.Config, hostType string) (*sql.DB, *dsnConnector, error) {
 	connStr, err := cfg.ConnectionString(ctx, awsConfig, hostType)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to create connection string")
-		return nil, err
+		return nil, nil, err
 	}
 
-	db, err := sql.Open("mysql", connStr)
+	connector, err := newDSNConnector(connStr)
 	if err != nil {
 		log.Error().Err(err).Msg("failed to open db connection")
-		return nil, err
+		return nil, nil, err
 	}
 
-	db.SetMaxOpenConns(5)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(1)
+	// Queries run with the context of the request get a span of their own.
+	db := otelsql.OpenDB(connector,
+		otelsql.WithAttributes(semconv.DBSystemMySQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{DisableErrSkip: true, OmitConnResetSession: true, OmitRows: true}),
+	)
+	configurePool(db, cfg)
 
 	if err := db.PingContext(ctx); err != nil {
 		log.Error().Err(err).Msg("failed to ping db")
-		return nil, err
+		return nil, nil, err
 	}
 
-	return db, nil
+	return db, connector, nil
+}
+
+// configurePool applies the pool sizes and connection lifetime of the settings.
+func configurePool(db *sql.DB, cfg DBConfig) {
+	db.SetMaxOpenConns(cfg.Database.MaxConns)
+	db.SetMaxIdleConns(cfg.Database.MinConns)
+	db.SetConnMaxLifetime(cfg.Database.MaxConnLifetime)
 }
 
 // TODO: Implement this function when se have two different hosts
-// func switchDBHost(cfg config.Settings, hostType string) string {
+// func switchDBHost(cfg config.DatabaseSettings, hostType string) string {
 //	var host string
 //
 //	switch hostType {
@@ -139,26 +219,3 @@ func newPool(ctx context.Context, cfg DBConfig, awsConfig aws.Config, hostType s
 //
 //	return host
 // }
-
-func MustSetupDB(ctx context.Context, awscfg aws.Config, dbConfig DBConfig) DB {
-	dbConn, err := NewDB(ctx, dbConfig, awscfg)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to setup db")
-	}
-
-	defaultConfig, err := awsConfig.LoadDefaultConfig(ctx)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to load aws config")
-	}
-
-	// Migrate the database
-	log.Info().Msg("Migrating database...")
-	err = Migrate(ctx, dbConfig, defaultConfig)
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to migrate db")
-	}
-
-	log.Info().Msg("DB setup successfully")
-
-	return dbConn
-}
@@ -4,17 +4,88 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/database"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// MigrationsSourceDir is where new migration files are created, relative to the repository root.
+	MigrationsSourceDir = "datastore/db/mysql/migrations"
+	// CoreMigrations names the migrations embedded in this package.
+	CoreMigrations = "core"
+
+	coreVersionTable = "goose_db_version"
 )
 
 //go:embed migrations/*.sql
 var embedMigrations embed.FS
 
+// MigrationSource is a set of goose SQL migrations, at the root of FS, tracked
+// in its own version table.
+type MigrationSource struct {
+	Name string
+	FS   fs.FS
+}
+
+func (src MigrationSource) versionTable() string {
+	if src.Name == CoreMigrations {
+		return coreVersionTable
+	}
+	return fmt.Sprintf("%s_%s", coreVersionTable, src.Name)
+}
+
+// migrationSources returns the core migrations followed by cfg.Migrations.
+func (cfg DBConfig) migrationSources() ([]MigrationSource, error) {
+	core, err := fs.Sub(embedMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]MigrationSource{{Name: CoreMigrations, FS: core}}, cfg.Migrations...), nil
+}
+
 func Migrate(ctx context.Context, cfg DBConfig, awsCfg aws.Config) error {
-	goose.SetBaseFS(embedMigrations)
+	return RunMigrations(ctx, cfg, awsCfg, "", "up")
+}
+
+// RunMigrations runs a goose command (up, down, status, redo...) against the
+// migration source named source, or against every source when it is empty.
+// Rolling back commands roll back the latest migration of one source, so they
+// need source once modules have migrations.
+func RunMigrations(ctx context.Context, cfg DBConfig, awsCfg aws.Config, source, command string, args ...string) error {
+	sources, err := cfg.migrationSources()
+	if err != nil {
+		return err
+	}
+
+	if source != "" {
+		var selected []MigrationSource
+		for _, src := range sources {
+			if src.Name == source {
+				selected = append(selected, src)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no migrations named %q", source)
+		}
+		sources = selected
+	}
+
+	if (command == "down" || command == "redo") && len(sources) > 1 {
+		names := make([]string, 0, len(sources))
+		for _, src := range sources {
+			names = append(names, src.Name)
+		}
+		return fmt.Errorf("%s rolls back the latest migration of one module, choose it with --module: %s", command, strings.Join(names, ", "))
+	}
 
 	if err := goose.SetDialect("mysql"); err != nil {
 		return err
@@ -31,5 +102,56 @@ func Migrate(ctx context.Context, cfg DBConfig, awsCfg aws.Config) error {
 	}
 	defer db.Close()
 
-	return goose.Up(db, "migrations", goose.WithAllowMissing())
+	for _, src := range sources {
+		log.Info().Msg(fmt.Sprintf("Running migrations %s: %s", command, src.Name))
+
+		goose.SetBaseFS(src.FS)
+		goose.SetTableName(src.versionTable())
+		if err := goose.RunWithOptionsContext(ctx, command, db, ".", args, goose.WithAllowMissing()); err != nil {
+			return fmt.Errorf("migrations %s: %w", src.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// CreateMigration writes a new timestamped SQL migration to MigrationsSourceDir.
+func CreateMigration(name string) error {
+	goose.SetBaseFS(nil)
+
+	return goose.Create(nil, MigrationsSourceDir, name, "sql")
+}
+
+// PendingMigrations returns the names of the migration sources with
+// migrations not applied to db yet.
+func PendingMigrations(ctx context.Context, db *sql.DB, cfg DBConfig) ([]string, error) {
+	sources, err := cfg.migrationSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []string
+	for _, src := range sources {
+		store, err := database.NewStore(database.DialectMySQL, src.versionTable())
+		if err != nil {
+			return nil, err
+		}
+		provider, err := goose.NewProvider("", db, src.FS, goose.WithStore(store), goose.WithAllowOutofOrder(true))
+		if errors.Is(err, goose.ErrNoMigrations) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("migrations %s: %w", src.Name, err)
+		}
+
+		hasPending, err := provider.HasPending(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("migrations %s: %w", src.Name, err)
+		}
+		if hasPending {
+			pending = append(pending, src.Name)
+		}
+	}
+
+	return pending, nil
 }
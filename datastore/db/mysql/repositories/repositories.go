@@ -0,0 +1,21 @@
+package repositories
+
+import (
+	"template/datastore/db/mysql"
+)
+
+// Repositories groups the repositories the services are built with.
+type Repositories struct {
+	Example RepositoryInterfaceExample
+}
+
+type exampleRepository struct {
+	db mysql.DB
+}
+
+// NewRepositories builds the MySQL backed repositories.
+func NewRepositories(db mysql.DB) Repositories {
+	return Repositories{
+		Example: &exampleRepository{db: db},
+	}
+}
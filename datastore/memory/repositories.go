@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"template/datastore/db/mysql/repositories"
+)
+
+type exampleRepository struct {
+}
+
+// NewRepositories builds in-memory repositories used when the service runs without a database.
+// Data lives for the lifetime of the process only.
+func NewRepositories() repositories.Repositories {
+	return repositories.Repositories{
+		Example: &exampleRepository{},
+	}
+}
@@ -0,0 +1,190 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Probe is one of the endpoints an orchestrator polls.
+type Probe string
+
+const (
+	// Liveness fails when the process should be restarted.
+	Liveness Probe = "liveness"
+	// Readiness fails while the instance should not receive traffic.
+	Readiness Probe = "readiness"
+	// Startup fails until the instance has finished starting.
+	Startup Probe = "startup"
+)
+
+const (
+	defaultTimeout  = 2 * time.Second
+	defaultCacheFor = 5 * time.Second
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check reports the health of one dependency.
+type Check struct {
+	Name string
+	// Probes the check belongs to, it only shows in the full report when empty.
+	Probes []Probe
+	// Timeout bounds a run of the check, 2s by default.
+	Timeout time.Duration
+	// CacheFor reuses the last result for this long, 5s by default, so busy
+	// probes don't hammer the dependency.
+	CacheFor time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	Latency   string    `json:"latency"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+}
+
+// Report is the outcome of the checks of a probe.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Up reports whether every check passed.
+func (r Report) Up() bool {
+	return r.Status == StatusUp
+}
+
+type registeredCheck struct {
+	Check
+
+	mu     sync.Mutex
+	last   Result
+	expiry time.Time
+}
+
+// Registry holds the health checks of the service.
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*registeredCheck
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a check. It fails when the name is taken or Run is missing.
+func (r *Registry) Register(check Check) error {
+	if check.Name == "" || check.Run == nil {
+		return errors.New("health check: a name and a Run function are required")
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = defaultTimeout
+	}
+	if check.CacheFor <= 0 {
+		check.CacheFor = defaultCacheFor
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.checks {
+		if c.Name == check.Name {
+			return fmt.Errorf("health check %q: already registered", check.Name)
+		}
+	}
+	r.checks = append(r.checks, &registeredCheck{Check: check})
+	return nil
+}
+
+// Run runs the checks of the probe, or every check when probe is empty, in
+// parallel. The report lists them in registration order.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	r.mu.RLock()
+	var checks []*registeredCheck
+	for _, c := range r.checks {
+		if probe == "" || c.belongsTo(probe) {
+			checks = append(checks, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusUp, Checks: make([]Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *registeredCheck) {
+			defer wg.Done()
+			report.Checks[i] = c.result(ctx)
+		}(i, c)
+	}
+	wg.Wait()
+
+	for _, result := range report.Checks {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+func (c *registeredCheck) belongsTo(probe Probe) bool {
+	for _, p := range c.Probes {
+		if p == probe {
+			return true
+		}
+	}
+	return false
+}
+
+// result returns the cached result while fresh and runs the check otherwise.
+// Concurrent callers wait for the same run.
+func (c *registeredCheck) result(ctx context.Context) Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Now().Before(c.expiry) {
+		cached := c.last
+		cached.Cached = true
+		return cached
+	}
+
+	// The result is shared, so a caller going away must not fail it.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.Timeout)
+	defer cancel()
+
+	started := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("timed out after %s", c.Timeout)
+	}
+
+	c.last = Result{
+		Name:      c.Name,
+		Status:    StatusUp,
+		Latency:   time.Since(started).String(),
+		CheckedAt: started,
+	}
+	if err != nil {
+		c.last.Status = StatusDown
+		c.last.Error = err.Error()
+	}
+	c.expiry = started.Add(c.CacheFor)
+
+	return c.last
+}
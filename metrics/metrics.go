@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels the requests no route matched, so unknown paths don't
+// each create a series.
+const unmatchedRoute = "unmatched"
+
+// Registry holds the Prometheus metrics of the service: HTTP requests, the
+// database pools, the Go runtime and the metrics registered by the services.
+type Registry struct {
+	registry *prometheus.Registry
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+}
+
+func NewRegistry() *Registry {
+	r := &Registry{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of the HTTP requests by method, route pattern and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.requests,
+		r.duration,
+	)
+	return r
+}
+
+// Counter registers a counter, or returns the one already registered under
+// that name with the same labels, so services can call it from their constructor.
+func (r *Registry) Counter(name, help string, labels ...string) (*prometheus.CounterVec, error) {
+	return register(r, prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels))
+}
+
+// Histogram registers a histogram, prometheus.DefBuckets when buckets is nil,
+// or returns the one already registered under that name with the same labels.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) (*prometheus.HistogramVec, error) {
+	return register(r, prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels))
+}
+
+// Register adds any other collector, e.g. a gauge.
+func (r *Registry) Register(collector prometheus.Collector) error {
+	return r.registry.Register(collector)
+}
+
+func register[T prometheus.Collector](r *Registry, collector T) (T, error) {
+	err := r.registry.Register(collector)
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(T); ok {
+			return existing, nil
+		}
+	}
+	if err != nil {
+		var zero T
+		return zero, fmt.Errorf("metric: %w", err)
+	}
+	return collector, nil
+}
+
+// MonitorDB exposes the sql.DB.Stats() of a pool, labeled with its name.
+func (r *Registry) MonitorDB(name string, db *sql.DB) error {
+	return r.registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Middleware counts the requests and measures their latency. It is used on the
+// top router so the route pattern is complete once the request is served.
+func (r *Registry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		started := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, req.ProtoMajor)
+		next.ServeHTTP(ww, req)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(req.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		labels := prometheus.Labels{"method": req.Method, "route": route, "status": strconv.Itoa(status)}
+		r.requests.With(labels).Inc()
+		r.duration.With(labels).Observe(time.Since(started).Seconds())
+	})
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{Registry: r.registry})
+}
@@ -0,0 +1,112 @@
+package modules
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/go-chi/chi/v5"
+
+	"template/config"
+	"template/datastore/db/mysql"
+	"template/health"
+	"template/metrics"
+	"template/worker"
+)
+
+// Module is a feature that plugs into the Starship. Feature packages register
+// one from an init function and are enabled with a blank import in main.go:
+//
+//	func init() {
+//		modules.Register(modules.Module{
+//			Name:         "contacts",
+//			DependsOn:    []string{"accounts"},
+//			Migrations:   migrationsFS,
+//			Repositories: newRepositories,
+//			Services:     newServices,
+//			Routes:       routes,
+//		})
+//	}
+//
+// Every field but Name is optional. The BuildDirector calls each step for all
+// modules in dependency order before moving on to the next step.
+type Module struct {
+	Name      string
+	DependsOn []string
+
+	// Migrations holds the goose SQL migrations of the module at its root. They
+	// are tracked in their own version table, so versions never clash with other modules.
+	Migrations fs.FS
+
+	// Repositories and Services build the module's components and publish them
+	// with Deps.Provide so that modules depending on this one can use them.
+	Repositories func(deps *Deps) error
+	Services     func(deps *Deps) error
+
+	// Routes are mounted under the API base path, or under <base path>/<version>
+	// for each of Versions when set, e.g. []string{"v1", "v2"}.
+	Routes   func(r chi.Router, deps *Deps)
+	Versions []string
+	// RequiresDatabase answers the module's routes with a 503 when the
+	// service runs without a database.
+	RequiresDatabase bool
+
+	// Workers returns the background consumers the module runs in worker mode.
+	Workers func(deps *Deps) ([]worker.Consumer, error)
+
+	// Start runs once everything is wired, before the server accepts requests
+	// or the workers start.
+	// Stop runs on shutdown, in reverse dependency order.
+	Start func(ctx context.Context) error
+	Stop  func(ctx context.Context) error
+}
+
+// Deps is shared by all modules while the Starship is being built.
+type Deps struct {
+	Mode     string
+	Settings *config.Settings
+	AwsCfg   aws.Config
+	// Database is nil when the service runs without a database, modules are
+	// expected to fall back to in-memory repositories.
+	Database *mysql.DB
+	// Health registers the health checks of the module's dependencies.
+	Health *health.Registry
+	// Metrics registers the counters and histograms of the module's services,
+	// exposed on /metrics of the admin server.
+	Metrics *metrics.Registry
+
+	values map[string]any
+}
+
+func NewDeps(mode string, settings *config.Settings, awsCfg aws.Config, database *mysql.DB) *Deps {
+	return &Deps{
+		Mode:     mode,
+		Settings: settings,
+		AwsCfg:   awsCfg,
+		Database: database,
+		values:   map[string]any{},
+	}
+}
+
+// Provide publishes a component under name, usually "<module>.<component>".
+func (d *Deps) Provide(name string, value any) {
+	d.values[name] = value
+}
+
+// Lookup returns a component published with Provide.
+func Lookup[T any](d *Deps, name string) (T, error) {
+	var zero T
+
+	value, ok := d.values[name]
+	if !ok {
+		return zero, fmt.Errorf("no component named %q was provided", name)
+	}
+
+	typed, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("component %q is a %T, not a %T", name, value, zero)
+	}
+
+	return typed, nil
+}
@@ -0,0 +1,110 @@
+package modules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"template/datastore/db/mysql"
+)
+
+var nameRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// Registry keeps the modules registered by feature packages.
+type Registry struct {
+	mu      sync.Mutex
+	modules []Module
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry is the registry Register adds to and the BuildDirector reads from.
+var DefaultRegistry = NewRegistry()
+
+// Register adds a module to the DefaultRegistry. It panics on an invalid or
+// duplicate module since it is meant to be called from init functions.
+func Register(m Module) {
+	if err := DefaultRegistry.Register(m); err != nil {
+		panic(err)
+	}
+}
+
+func (r *Registry) Register(m Module) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if m.Name == "" {
+		return fmt.Errorf("module name is required")
+	}
+	// The name suffixes the version table of the module's migrations.
+	if !nameRegex.MatchString(m.Name) {
+		return fmt.Errorf("module name %q must be lowercase letters, digits and underscores, starting with a letter", m.Name)
+	}
+	if m.Name == mysql.CoreMigrations {
+		return fmt.Errorf("module name %q is reserved for the built-in migrations", m.Name)
+	}
+	for _, existing := range r.modules {
+		if existing.Name == m.Name {
+			return fmt.Errorf("module %q is already registered", m.Name)
+		}
+	}
+
+	r.modules = append(r.modules, m)
+	return nil
+}
+
+// Resolve returns the modules ordered so that every module comes after the
+// ones it depends on. Modules without a dependency between them keep their
+// registration order.
+func (r *Registry) Resolve() ([]Module, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byName := make(map[string]Module, len(r.modules))
+	for _, m := range r.modules {
+		byName[m.Name] = m
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(r.modules))
+	ordered := make([]Module, 0, len(r.modules))
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("module dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+
+		m := byName[name]
+		state[name] = visiting
+		for _, dep := range m.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return fmt.Errorf("module %q depends on %q, which is not registered", name, dep)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		ordered = append(ordered, m)
+
+		return nil
+	}
+
+	for _, m := range r.modules {
+		if err := visit(m.Name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
@@ -0,0 +1,125 @@
+package modules
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRegister(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  string
+		wantErr string
+	}{
+		{name: "valid", module: "user_accounts"},
+		{name: "empty", module: "", wantErr: "module name is required"},
+		{name: "reserved", module: "core", wantErr: "reserved"},
+		{name: "hyphen", module: "user-accounts", wantErr: "lowercase letters"},
+		{name: "leading digit", module: "2fa", wantErr: "lowercase letters"},
+		{name: "uppercase", module: "Users", wantErr: "lowercase letters"},
+		{name: "duplicate", module: "users", wantErr: "already registered"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			if err := r.Register(Module{Name: "users"}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := r.Register(Module{Name: tt.module})
+
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Register: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tests := []struct {
+		name    string
+		modules []Module
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "registration order",
+			modules: []Module{
+				{Name: "users"},
+				{Name: "orders"},
+			},
+			want: []string{"users", "orders"},
+		},
+		{
+			name: "dependencies first",
+			modules: []Module{
+				{Name: "invoices", DependsOn: []string{"orders", "users"}},
+				{Name: "orders", DependsOn: []string{"users"}},
+				{Name: "reports"},
+				{Name: "users"},
+			},
+			want: []string{"users", "orders", "invoices", "reports"},
+		},
+		{
+			name: "cycle",
+			modules: []Module{
+				{Name: "users"},
+				{Name: "orders", DependsOn: []string{"invoices"}},
+				{Name: "invoices", DependsOn: []string{"orders"}},
+			},
+			wantErr: "module dependency cycle: orders -> invoices -> orders",
+		},
+		{
+			name: "self dependency",
+			modules: []Module{
+				{Name: "users", DependsOn: []string{"users"}},
+			},
+			wantErr: "module dependency cycle: users -> users",
+		},
+		{
+			name: "missing dependency",
+			modules: []Module{
+				{Name: "orders", DependsOn: []string{"users"}},
+			},
+			wantErr: `module "orders" depends on "users", which is not registered`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			for _, m := range tt.modules {
+				if err := r.Register(m); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resolved, err := r.Resolve()
+
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve: %v", err)
+			}
+			names := make([]string, 0, len(resolved))
+			for _, m := range resolved {
+				names = append(names, m.Name)
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("order = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
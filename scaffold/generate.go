@@ -0,0 +1,158 @@
+package scaffold
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	mocksFile = "test/mocks/generate.go"
+	mainFile  = "main.go"
+)
+
+type output struct {
+	path     string
+	template string
+}
+
+func (res Resource) outputs(now time.Time) []output {
+	return []output{
+		{filepath.Join("datastore/db/mysql/migrations", fmt.Sprintf("%s_create_%s.sql", now.UTC().Format("20060102150405"), res.Table)), migrationTemplate},
+		{filepath.Join("domain/models", res.Name+".go"), modelTemplate},
+		{filepath.Join("datastore/db/mysql/repositories", res.Name+".go"), repositoryTemplate},
+		{filepath.Join("datastore/memory", res.Name+".go"), memoryRepositoryTemplate},
+		{filepath.Join("domain", res.Name+".go"), serviceTemplate},
+		{filepath.Join("apiserver/handlers", res.Name+".go"), handlerTemplate},
+		{filepath.Join("modules", res.Package, "module.go"), moduleTemplate},
+	}
+}
+
+// Generate writes the migration, model, repositories, service, handlers and
+// module of a resource under root, adds its mockgen directives and enables the
+// module in main.go. It returns the files it wrote or changed and never
+// overwrites an existing file.
+func Generate(root string, res Resource, now time.Time) ([]string, error) {
+	outputs := res.outputs(now)
+
+	existing, err := filepath.Glob(filepath.Join(root, "datastore/db/mysql/migrations", "*_create_"+res.Table+".sql"))
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) > 0 {
+		return nil, fmt.Errorf("a migration creating %s already exists: %s", res.Table, existing[0])
+	}
+	for _, out := range outputs {
+		if _, err := os.Stat(filepath.Join(root, out.path)); err == nil {
+			return nil, fmt.Errorf("%s already exists", out.path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	// Render everything first so that a template failing to render or format
+	// leaves the tree untouched.
+	type file struct {
+		path    string
+		content []byte
+	}
+	var files []file
+	for _, out := range outputs {
+		content, err := render(out, res)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{out.path, content})
+	}
+
+	mocks := []string{
+		fmt.Sprintf("//go:generate mockgen -source=../../domain/%s.go -package=mocks -destination=mock_domain_%s.go", res.Name, res.Name),
+		fmt.Sprintf("//go:generate mockgen -source=../../datastore/db/mysql/repositories/%s.go -package=mocks -destination=mock_repos_%s.go", res.Name, res.Name),
+	}
+	content, err := os.ReadFile(filepath.Join(root, mocksFile))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, file{mocksFile, addMockDirectives(content, mocks)})
+
+	content, err = os.ReadFile(filepath.Join(root, mainFile))
+	if err != nil {
+		return nil, err
+	}
+	content, err = enableModule(mainFile, content, "template/modules/"+res.Package)
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, file{mainFile, content})
+
+	var written []string
+	for _, f := range files {
+		path := filepath.Join(root, f.path)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(path, f.content, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, f.path)
+	}
+
+	return written, nil
+}
+
+func render(out output, res Resource) ([]byte, error) {
+	tmpl, err := template.New(out.path).Parse(out.template)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, res); err != nil {
+		return nil, err
+	}
+
+	if filepath.Ext(out.path) != ".go" {
+		return buf.Bytes(), nil
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", out.path, err)
+	}
+	return formatted, nil
+}
+
+// addMockDirectives appends go:generate lines after the existing ones.
+func addMockDirectives(content []byte, directives []string) []byte {
+	lines := strings.Split(string(content), "\n")
+	last := -1
+	for i, line := range lines {
+		if strings.HasPrefix(line, "//go:generate") {
+			last = i
+		}
+	}
+
+	updated := append([]string{}, lines[:last+1]...)
+	updated = append(updated, directives...)
+	updated = append(updated, lines[last+1:]...)
+
+	return []byte(strings.Join(updated, "\n"))
+}
+
+// enableModule adds a blank import of the module package to main.go.
+func enableModule(path string, content []byte, importPath string) ([]byte, error) {
+	src := string(content)
+	anchor := "\t\"template/cmd\"\n"
+	if !strings.Contains(src, anchor) {
+		return nil, fmt.Errorf("could not find the import of template/cmd in %s, add _ %q to its imports", path, importPath)
+	}
+	src = strings.Replace(src, anchor, anchor+fmt.Sprintf("\t_ %q\n", importPath), 1)
+
+	return format.Source([]byte(src))
+}
@@ -0,0 +1,130 @@
+package scaffold
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// handlerTest runs the generated handlers against the in-memory repository.
+const handlerTest = `package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"template/datastore/memory"
+	"template/domain"
+)
+
+func TestWidgetHandler(t *testing.T) {
+	r := chi.NewRouter()
+	NewWidgetHandler(domain.NewWidgetService(memory.NewWidgetRepository())).Routes(r)
+
+	requests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/widgets/1", "", http.StatusNotFound},
+		{http.MethodPost, "/widgets", ` + "`" + `{"name": "sprocket"}` + "`" + `, http.StatusCreated},
+		{http.MethodGet, "/widgets/1", "", http.StatusOK},
+		{http.MethodDelete, "/widgets/1", "", http.StatusNoContent},
+		{http.MethodDelete, "/widgets/1", "", http.StatusNotFound},
+		{http.MethodGet, "/widgets/x", "", http.StatusBadRequest},
+	}
+	for _, req := range requests {
+		w := httptest.NewRecorder()
+		request := httptest.NewRequest(req.method, req.path, strings.NewReader(req.body))
+		request.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, request)
+		if w.Code != req.want {
+			t.Errorf("%s %s = %d, want %d: %s", req.method, req.path, w.Code, req.want, w.Body)
+		}
+		if w.Code < http.StatusBadRequest {
+			continue
+		}
+		var body struct {
+			Status int ` + "`" + `json:"status"` + "`" + `
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Status != w.Code {
+			t.Errorf("%s %s answered %d with %s", req.method, req.path, w.Code, w.Body)
+		}
+	}
+}
+`
+
+// TestGenerate generates a resource in a copy of the repository, then builds
+// it and runs the generated handlers.
+func TestGenerate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds a copy of the repository")
+	}
+
+	root := t.TempDir()
+	if err := copyTree("..", root); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := NewResource("widget", "name:string,weight:float")
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := Generate(root, res, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if len(written) != len(res.outputs(time.Now()))+2 {
+		t.Errorf("wrote %v", written)
+	}
+
+	if _, err := Generate(root, res, time.Now()); err == nil {
+		t.Error("Generate overwrote the resource")
+	}
+
+	test := filepath.Join(root, "apiserver/handlers/widget_test.go")
+	if err := os.WriteFile(test, []byte(handlerTest), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"build", "./..."},
+		{"vet", "./..."},
+		{"test", "-run", "TestWidgetHandler", "./apiserver/handlers/"},
+	} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("go %v: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(filepath.Join(dst, rel), 0o755)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, rel), content, 0o644)
+	})
+}
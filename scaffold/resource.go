@@ -0,0 +1,177 @@
+package scaffold
+
+import (
+	"fmt"
+	"go/token"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	identifierRegex = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	reservedFields  = map[string]bool{"id": true, "created_at": true, "updated_at": true}
+	initialisms     = map[string]string{"id": "ID", "url": "URL", "uri": "URI", "api": "API", "ip": "IP", "json": "JSON", "uuid": "UUID", "http": "HTTP"}
+)
+
+type fieldType struct {
+	GoType  string
+	SQLType string
+}
+
+var fieldTypes = map[string]fieldType{
+	"string":  {"string", "VARCHAR(255)"},
+	"text":    {"string", "TEXT"},
+	"int":     {"int", "INT"},
+	"int64":   {"int64", "BIGINT"},
+	"float":   {"float64", "DOUBLE"},
+	"float64": {"float64", "DOUBLE"},
+	"bool":    {"bool", "BOOLEAN"},
+	"time":    {"time.Time", "DATETIME"},
+}
+
+// Field is a column of a generated resource.
+type Field struct {
+	Name    string // snake_case, used for columns and JSON keys
+	GoName  string
+	GoType  string
+	SQLType string
+}
+
+// Resource describes the resource to generate, with every name derived from the one given on the command line.
+type Resource struct {
+	Name       string // snake_case singular, file names
+	GoName     string // CamelCase singular, types
+	VarName    string // camelCase singular, variables
+	Table      string // snake_case plural, table name
+	Package    string // lowercase plural, module package
+	RoutePath  string // kebab-case plural, URL path
+	URLParam   string
+	Fields     []Field
+	NeedsTime  bool
+	ModuleName string
+}
+
+// NewResource parses the resource name and a comma separated list of name:type fields.
+func NewResource(name, fields string) (Resource, error) {
+	snake := toSnake(name)
+	if !identifierRegex.MatchString(snake) {
+		return Resource{}, fmt.Errorf("invalid resource name %q", name)
+	}
+
+	plural := pluralize(snake)
+	res := Resource{
+		Name:       snake,
+		GoName:     toCamel(snake),
+		VarName:    toLowerCamel(snake),
+		Table:      plural,
+		Package:    strings.ReplaceAll(plural, "_", ""),
+		RoutePath:  strings.ReplaceAll(plural, "_", "-"),
+		URLParam:   toLowerCamel(snake) + "ID",
+		ModuleName: plural,
+	}
+	if token.IsKeyword(res.VarName) || token.IsKeyword(res.Package) {
+		return Resource{}, fmt.Errorf("invalid resource name %q, %s and %s must not be Go keywords", name, res.VarName, res.Package)
+	}
+	if res.Package == "modules" {
+		return Resource{}, fmt.Errorf("invalid resource name %q, its package would clash with template/modules", name)
+	}
+
+	if strings.TrimSpace(fields) == "" {
+		return Resource{}, fmt.Errorf("at least one field is required, e.g. --fields name:string,email:string")
+	}
+
+	seen := map[string]bool{}
+	for _, spec := range strings.Split(fields, ",") {
+		parts := strings.SplitN(strings.TrimSpace(spec), ":", 2)
+		if len(parts) != 2 {
+			return Resource{}, fmt.Errorf("invalid field %q, expected name:type", spec)
+		}
+
+		fieldName := toSnake(parts[0])
+		if !identifierRegex.MatchString(fieldName) {
+			return Resource{}, fmt.Errorf("invalid field name %q", parts[0])
+		}
+		if reservedFields[fieldName] {
+			return Resource{}, fmt.Errorf("field %q is generated for every resource", fieldName)
+		}
+		if seen[fieldName] {
+			return Resource{}, fmt.Errorf("field %q is declared twice", fieldName)
+		}
+		seen[fieldName] = true
+
+		ft, ok := fieldTypes[strings.ToLower(parts[1])]
+		if !ok {
+			return Resource{}, fmt.Errorf("unsupported type %q for field %s, use one of %s", parts[1], fieldName, supportedTypes())
+		}
+		if ft.GoType == "time.Time" {
+			res.NeedsTime = true
+		}
+
+		res.Fields = append(res.Fields, Field{
+			Name:    fieldName,
+			GoName:  toCamel(fieldName),
+			GoType:  ft.GoType,
+			SQLType: ft.SQLType,
+		})
+	}
+
+	return res, nil
+}
+
+func supportedTypes() string {
+	return "string, text, int, int64, float, float64, bool, time"
+}
+
+func toSnake(s string) string {
+	var b strings.Builder
+	runes := []rune(strings.TrimSpace(s))
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == ' ':
+			b.WriteRune('_')
+		case unicode.IsUpper(r):
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteRune('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func toCamel(snake string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(snake, "_") {
+		if part == "" {
+			continue
+		}
+		if initialism, ok := initialisms[part]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func toLowerCamel(snake string) string {
+	parts := strings.SplitN(snake, "_", 2)
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return parts[0] + toCamel(parts[1])
+}
+
+func pluralize(s string) string {
+	switch {
+	case strings.HasSuffix(s, "y") && len(s) > 1 && !strings.ContainsRune("aeiou", rune(s[len(s)-2])):
+		return s[:len(s)-1] + "ies"
+	case strings.HasSuffix(s, "s"), strings.HasSuffix(s, "x"), strings.HasSuffix(s, "ch"), strings.HasSuffix(s, "sh"):
+		return s + "es"
+	default:
+		return s + "s"
+	}
+}
@@ -0,0 +1,496 @@
+package scaffold
+
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE {{.Table}} (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+{{- range .Fields}}
+    {{.Name}} {{.SQLType}} NOT NULL,
+{{- end}}
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (id)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE {{.Table}};
+-- +goose StatementEnd
+`
+
+const modelTemplate = `package models
+
+import "time"
+
+type {{.GoName}} struct {
+	ID int64 ` + "`json:\"id\"`" + `
+{{- range .Fields}}
+	{{.GoName}} {{.GoType}} ` + "`json:\"{{.Name}}\"`" + `
+{{- end}}
+	CreatedAt time.Time ` + "`json:\"created_at\"`" + `
+	UpdatedAt time.Time ` + "`json:\"updated_at\"`" + `
+}
+`
+
+const repositoryTemplate = `package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"template/datastore/db/mysql"
+	"template/domain/models"
+)
+
+type {{.GoName}}RepositoryInterface interface {
+	List(ctx context.Context) ([]models.{{.GoName}}, error)
+	Get(ctx context.Context, id int64) (*models.{{.GoName}}, error)
+	Create(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error
+	Update(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error
+	Delete(ctx context.Context, id int64) error
+}
+
+const {{.VarName}}Columns = "id, {{range .Fields}}{{.Name}}, {{end}}created_at, updated_at"
+
+type {{.VarName}}Repository struct {
+	db mysql.DB
+}
+
+func New{{.GoName}}Repository(db mysql.DB) {{.GoName}}RepositoryInterface {
+	return &{{.VarName}}Repository{db: db}
+}
+
+func scan{{.GoName}}(row interface{ Scan(dest ...any) error }) (*models.{{.GoName}}, error) {
+	var {{.VarName}} models.{{.GoName}}
+	err := row.Scan(&{{.VarName}}.ID, {{range .Fields}}&{{$.VarName}}.{{.GoName}}, {{end}}&{{.VarName}}.CreatedAt, &{{.VarName}}.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &{{.VarName}}, nil
+}
+
+func (r *{{.VarName}}Repository) List(ctx context.Context) ([]models.{{.GoName}}, error) {
+	rows, err := r.db.PoolRead.QueryContext(ctx, "SELECT "+{{.VarName}}Columns+" FROM {{.Table}} ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	{{.VarName}}List := []models.{{.GoName}}{}
+	for rows.Next() {
+		{{.VarName}}, err := scan{{.GoName}}(rows)
+		if err != nil {
+			return nil, err
+		}
+		{{.VarName}}List = append({{.VarName}}List, *{{.VarName}})
+	}
+
+	return {{.VarName}}List, rows.Err()
+}
+
+func (r *{{.VarName}}Repository) Get(ctx context.Context, id int64) (*models.{{.GoName}}, error) {
+	return r.get(ctx, r.db.PoolRead, id)
+}
+
+// get reads from the write pool after a change so replication lag can't hide it.
+func (r *{{.VarName}}Repository) get(ctx context.Context, pool *sql.DB, id int64) (*models.{{.GoName}}, error) {
+	row := pool.QueryRowContext(ctx, "SELECT "+{{.VarName}}Columns+" FROM {{.Table}} WHERE id = ?", id)
+	return scan{{.GoName}}(row)
+}
+
+func (r *{{.VarName}}Repository) Create(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error {
+	res, err := r.db.Pool.ExecContext(ctx,
+		"INSERT INTO {{.Table}} ({{range $i, $f := .Fields}}{{if $i}}, {{end}}{{$f.Name}}{{end}}) VALUES ({{range $i, $f := .Fields}}{{if $i}}, {{end}}?{{end}})",
+		{{range .Fields}}{{$.VarName}}.{{.GoName}}, {{end}})
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	created, err := r.get(ctx, r.db.Pool, id)
+	if err != nil {
+		return err
+	}
+	*{{.VarName}} = *created
+
+	return nil
+}
+
+func (r *{{.VarName}}Repository) Update(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error {
+	_, err := r.db.Pool.ExecContext(ctx,
+		"UPDATE {{.Table}} SET {{range $i, $f := .Fields}}{{if $i}}, {{end}}{{$f.Name}} = ?{{end}} WHERE id = ?",
+		{{range .Fields}}{{$.VarName}}.{{.GoName}}, {{end}}{{.VarName}}.ID)
+	if err != nil {
+		return err
+	}
+
+	updated, err := r.get(ctx, r.db.Pool, {{.VarName}}.ID)
+	if err != nil {
+		return err
+	}
+	*{{.VarName}} = *updated
+
+	return nil
+}
+
+func (r *{{.VarName}}Repository) Delete(ctx context.Context, id int64) error {
+	res, err := r.db.Pool.ExecContext(ctx, "DELETE FROM {{.Table}} WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return models.ErrNotFound
+	}
+
+	return nil
+}
+`
+
+const memoryRepositoryTemplate = `package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"template/datastore/db/mysql/repositories"
+	"template/domain/models"
+)
+
+type {{.VarName}}Repository struct {
+	mu     sync.RWMutex
+	nextID int64
+	items  map[int64]models.{{.GoName}}
+}
+
+func New{{.GoName}}Repository() repositories.{{.GoName}}RepositoryInterface {
+	return &{{.VarName}}Repository{items: map[int64]models.{{.GoName}}{}}
+}
+
+func (r *{{.VarName}}Repository) List(_ context.Context) ([]models.{{.GoName}}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	{{.VarName}}List := make([]models.{{.GoName}}, 0, len(r.items))
+	for _, {{.VarName}} := range r.items {
+		{{.VarName}}List = append({{.VarName}}List, {{.VarName}})
+	}
+	sort.Slice({{.VarName}}List, func(i, j int) bool { return {{.VarName}}List[i].ID < {{.VarName}}List[j].ID })
+
+	return {{.VarName}}List, nil
+}
+
+func (r *{{.VarName}}Repository) Get(_ context.Context, id int64) (*models.{{.GoName}}, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	{{.VarName}}, ok := r.items[id]
+	if !ok {
+		return nil, models.ErrNotFound
+	}
+
+	return &{{.VarName}}, nil
+}
+
+func (r *{{.VarName}}Repository) Create(_ context.Context, {{.VarName}} *models.{{.GoName}}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	{{.VarName}}.ID = r.nextID
+	{{.VarName}}.CreatedAt = now
+	{{.VarName}}.UpdatedAt = now
+	r.items[{{.VarName}}.ID] = *{{.VarName}}
+
+	return nil
+}
+
+func (r *{{.VarName}}Repository) Update(_ context.Context, {{.VarName}} *models.{{.GoName}}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.items[{{.VarName}}.ID]
+	if !ok {
+		return models.ErrNotFound
+	}
+	{{.VarName}}.CreatedAt = existing.CreatedAt
+	{{.VarName}}.UpdatedAt = time.Now()
+	r.items[{{.VarName}}.ID] = *{{.VarName}}
+
+	return nil
+}
+
+func (r *{{.VarName}}Repository) Delete(_ context.Context, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.items[id]; !ok {
+		return models.ErrNotFound
+	}
+	delete(r.items, id)
+
+	return nil
+}
+`
+
+const serviceTemplate = `package domain
+
+import (
+	"context"
+
+	"template/datastore/db/mysql/repositories"
+	"template/domain/models"
+)
+
+type {{.GoName}}ServiceInterface interface {
+	List(ctx context.Context) ([]models.{{.GoName}}, error)
+	Get(ctx context.Context, id int64) (*models.{{.GoName}}, error)
+	Create(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error
+	Update(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type {{.VarName}}Service struct {
+	repository repositories.{{.GoName}}RepositoryInterface
+}
+
+func New{{.GoName}}Service(repository repositories.{{.GoName}}RepositoryInterface) {{.GoName}}ServiceInterface {
+	return &{{.VarName}}Service{repository: repository}
+}
+
+func (s *{{.VarName}}Service) List(ctx context.Context) ([]models.{{.GoName}}, error) {
+	return s.repository.List(ctx)
+}
+
+func (s *{{.VarName}}Service) Get(ctx context.Context, id int64) (*models.{{.GoName}}, error) {
+	return s.repository.Get(ctx, id)
+}
+
+func (s *{{.VarName}}Service) Create(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error {
+	return s.repository.Create(ctx, {{.VarName}})
+}
+
+func (s *{{.VarName}}Service) Update(ctx context.Context, {{.VarName}} *models.{{.GoName}}) error {
+	return s.repository.Update(ctx, {{.VarName}})
+}
+
+func (s *{{.VarName}}Service) Delete(ctx context.Context, id int64) error {
+	return s.repository.Delete(ctx, id)
+}
+`
+
+const handlerTemplate = `package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+{{- if .NeedsTime}}
+	"time"
+{{- end}}
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+
+	"template/domain"
+	"template/domain/models"
+)
+
+type {{.GoName}}Handler struct {
+	service domain.{{.GoName}}ServiceInterface
+}
+
+func New{{.GoName}}Handler(service domain.{{.GoName}}ServiceInterface) *{{.GoName}}Handler {
+	return &{{.GoName}}Handler{service: service}
+}
+
+type {{.GoName}}Request struct {
+{{- range .Fields}}
+	{{.GoName}} {{.GoType}} ` + "`json:\"{{.Name}}\"`" + `
+{{- end}}
+}
+
+func (req *{{.GoName}}Request) Bind(r *http.Request) error {
+	return nil
+}
+
+func (req *{{.GoName}}Request) apply({{.VarName}} *models.{{.GoName}}) {
+{{- range .Fields}}
+	{{$.VarName}}.{{.GoName}} = req.{{.GoName}}
+{{- end}}
+}
+
+func (h *{{.GoName}}Handler) Routes(r chi.Router) {
+	r.Route("/{{.RoutePath}}", func(r chi.Router) {
+		r.Get("/", h.List)
+		r.Post("/", h.Create)
+		r.Route("/{ {{- .URLParam -}} }", func(r chi.Router) {
+			r.Get("/", h.Get)
+			r.Put("/", h.Update)
+			r.Delete("/", h.Delete)
+		})
+	})
+}
+
+func (h *{{.GoName}}Handler) List(w http.ResponseWriter, r *http.Request) {
+	{{.VarName}}List, err := h.service.List(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrInternalServer(err))
+		return
+	}
+
+	render.JSON(w, r, {{.VarName}}List)
+}
+
+func (h *{{.GoName}}Handler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "{{.URLParam}}"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	{{.VarName}}, err := h.service.Get(r.Context(), id)
+	if err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	render.JSON(w, r, {{.VarName}})
+}
+
+func (h *{{.GoName}}Handler) Create(w http.ResponseWriter, r *http.Request) {
+	var req {{.GoName}}Request
+	if err := render.Bind(r, &req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	var {{.VarName}} models.{{.GoName}}
+	req.apply(&{{.VarName}})
+	if err := h.service.Create(r.Context(), &{{.VarName}}); err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, {{.VarName}})
+}
+
+func (h *{{.GoName}}Handler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "{{.URLParam}}"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	var req {{.GoName}}Request
+	if err := render.Bind(r, &req); err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	{{.VarName}} := models.{{.GoName}}{ID: id}
+	req.apply(&{{.VarName}})
+	if err := h.service.Update(r.Context(), &{{.VarName}}); err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	render.JSON(w, r, {{.VarName}})
+}
+
+func (h *{{.GoName}}Handler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "{{.URLParam}}"), 10, 64)
+	if err != nil {
+		render.Render(w, r, ErrInvalidRequest(err))
+		return
+	}
+
+	if err := h.service.Delete(r.Context(), id); err != nil {
+		h.renderError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *{{.GoName}}Handler) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, models.ErrNotFound) {
+		render.Render(w, r, ErrResourceNotFound(err))
+		return
+	}
+
+	render.Render(w, r, ErrInternalServer(err))
+}
+`
+
+const moduleTemplate = `package {{.Package}}
+
+import (
+	"github.com/go-chi/chi/v5"
+
+	"template/apiserver/handlers"
+	"template/datastore/db/mysql/repositories"
+	"template/datastore/memory"
+	"template/domain"
+	"template/modules"
+)
+
+const (
+	repositoryName = "{{.ModuleName}}.repository"
+	serviceName    = "{{.ModuleName}}.service"
+)
+
+func init() {
+	modules.Register(newModule())
+}
+
+func newModule() modules.Module {
+	var handler *handlers.{{.GoName}}Handler
+
+	return modules.Module{
+		Name: "{{.ModuleName}}",
+		Repositories: func(deps *modules.Deps) error {
+			if deps.Database == nil {
+				deps.Provide(repositoryName, memory.New{{.GoName}}Repository())
+				return nil
+			}
+
+			deps.Provide(repositoryName, repositories.New{{.GoName}}Repository(*deps.Database))
+			return nil
+		},
+		Services: func(deps *modules.Deps) error {
+			repository, err := modules.Lookup[repositories.{{.GoName}}RepositoryInterface](deps, repositoryName)
+			if err != nil {
+				return err
+			}
+
+			service := domain.New{{.GoName}}Service(repository)
+			deps.Provide(serviceName, service)
+			handler = handlers.New{{.GoName}}Handler(service)
+
+			return nil
+		},
+		Routes: func(r chi.Router, _ *modules.Deps) {
+			handler.Routes(r)
+		},
+	}
+}
+`
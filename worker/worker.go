@@ -0,0 +1,102 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const defaultRestartDelay = 5 * time.Second
+
+// Consumer is a background loop, e.g. polling a queue and handling its jobs.
+type Consumer struct {
+	Name string
+	// Concurrency is how many copies of Run execute in parallel. Zero uses the
+	// runner's default.
+	Concurrency int
+	// Run must return once ctx is cancelled. A Run returning an error, or
+	// panicking, is restarted after RestartDelay. A Run returning nil before
+	// ctx is cancelled is considered done and is not restarted.
+	Run          func(ctx context.Context) error
+	RestartDelay time.Duration
+}
+
+// Runner runs consumers until their context is cancelled.
+type Runner struct {
+	defaultConcurrency int
+	consumers          []Consumer
+}
+
+func NewRunner(defaultConcurrency int) *Runner {
+	if defaultConcurrency <= 0 {
+		defaultConcurrency = 1
+	}
+
+	return &Runner{
+		defaultConcurrency: defaultConcurrency,
+	}
+}
+
+func (r *Runner) Add(c Consumer) {
+	r.consumers = append(r.consumers, c)
+}
+
+// Run starts every consumer and blocks until all of them returned.
+func (r *Runner) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, c := range r.consumers {
+		concurrency := c.Concurrency
+		if concurrency <= 0 {
+			concurrency = r.defaultConcurrency
+		}
+
+		log.Info().Msg(fmt.Sprintf("Starting worker %s with concurrency %d", c.Name, concurrency))
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func(c Consumer, instance int) {
+				defer wg.Done()
+				r.loop(ctx, c, instance)
+			}(c, i)
+		}
+	}
+
+	wg.Wait()
+}
+
+func (r *Runner) loop(ctx context.Context, c Consumer, instance int) {
+	delay := c.RestartDelay
+	if delay <= 0 {
+		delay = defaultRestartDelay
+	}
+
+	for {
+		err := runSafely(ctx, c)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			log.Info().Msg(fmt.Sprintf("Worker %s #%d finished", c.Name, instance))
+			return
+		}
+
+		log.Error().Err(err).Msg(fmt.Sprintf("Worker %s #%d failed, restarting in %s", c.Name, instance, delay))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func runSafely(ctx context.Context, c Consumer) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+
+	return c.Run(ctx)
+}